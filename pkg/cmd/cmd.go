@@ -2,11 +2,16 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/ibrokethecloud/sim-cli/pkg/docker"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/ibrokethecloud/sim-cli/pkg/settings"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -16,8 +21,24 @@ var (
 	config = Simulator{
 		Ctx: context.TODO(),
 	}
-	verbose bool
-	Image   = "rancher/support-bundle-kit:dev"
+	verbose     bool
+	logOptions  runtime.LogOptions
+	listOptions ListOptions
+	interactive bool
+	localPort   int
+	imageFile   string
+	dryRun      bool
+	timeout     time.Duration
+	output      string
+	tty         bool
+	runtimeName string
+	// cancelTimeout releases the resources of the command timeout once the command completes
+	cancelTimeout context.CancelFunc = func() {}
+	// clientOptions identify the docker daemon to connect to
+	clientOptions docker.ClientOptions
+	Image         = "rancher/support-bundle-kit:dev"
+	// Version of sim-cli recorded on instances, set at build time
+	Version = "dev"
 )
 
 // define sub comamnds
@@ -26,39 +47,121 @@ func init() {
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(restartCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(tunnelCmd)
+	rootCmd.AddCommand(imageCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(describeCmd)
+	imageCmd.AddCommand(imagePullCmd)
+	imageCmd.AddCommand(imageSaveCmd)
+	imageCmd.AddCommand(imageLoadCmd)
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "verbose output")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "maximum time to run the command for, like 10m. defaults to no timeout")
+	rootCmd.PersistentFlags().StringVar(&runtimeName, "runtime", runtime.Docker, "container runtime to use, docker or podman. defaults to runtime in $HOME/.sim/config.yaml if set")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.Context, "docker-context", "", "name of the docker context to use, overrides DOCKER_HOST and the current docker context")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.Host, "docker-host", "", "docker daemon to connect to, like tcp://10.0.0.5:2376 or ssh://user@host")
+	rootCmd.PersistentFlags().BoolVar(&clientOptions.TLS, "tls", false, "use tls when connecting to the docker daemon, implied by --tlsverify")
+	rootCmd.PersistentFlags().BoolVar(&clientOptions.TLSVerify, "tlsverify", false, "use tls and verify the docker daemon certificate")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.TLSCACert, "tlscacert", "", "trust docker daemon certificates signed only by this CA, defaults to ca.pem in the docker cert path")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.TLSCert, "tlscert", "", "path to tls client certificate file, defaults to cert.pem in the docker cert path")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.TLSKey, "tlskey", "", "path to tls client key file, defaults to key.pem in the docker cert path")
 	createCmd.Flags().StringVar(&config.Name, "name", "", "name of simulator instance")
 	createCmd.MarkFlagRequired("name") // instance name is a mandatory flag
-	createCmd.Flags().StringVar(&config.BundlePath, "bundle-path", "", "location to bundle path")
+	createCmd.Flags().StringVar(&config.BundlePath, "bundle-path", "", "location of the support bundle, a zip, tar, tar.gz or tar.zst file, or an extracted bundle directory")
 	createCmd.MarkFlagRequired("bundle-path") // bundle path is a mandatory path
 	createCmd.Flags().StringVar(&config.Image, "image", Image, "image to use")
+	createCmd.Flags().IntVar(&config.HostPort, "port", 0, "host port to publish simulator api server on, defaults to a random port")
+	createCmd.Flags().StringVar(&config.PortRange, "port-range", "", "range of host ports, in the form start-end, to select a free port from for the simulator api server")
+	createCmd.MarkFlagsMutuallyExclusive("port", "port-range")
+	createCmd.Flags().StringVar(&config.BindAddress, "bind-address", "127.0.0.1", "host address to publish simulator api server on")
+	createCmd.Flags().StringVar(&config.PullPolicy, "pull", PullMissing, "when to pull the base image, always, missing or never")
+	createCmd.Flags().StringVar(&config.Mode, "mode", ModeImage, "how the bundle is loaded into the simulator, image builds an image per instance and mount mounts the bundle from a shared volume")
+	createCmd.Flags().DurationVar(&config.WaitTimeout, "wait-timeout", 5*time.Minute, "time to wait for simulator api server to become ready")
+	createCmd.Flags().StringVar(&config.CPUs, "cpus", "", "number of cpus available to the simulator, like 1.5. defaults to cpus in $HOME/.sim/config.yaml if set, otherwise unlimited")
+	createCmd.Flags().StringVar(&config.Memory, "memory", "", "memory available to the simulator, like 4g. defaults to memory in $HOME/.sim/config.yaml if set, otherwise unlimited")
+	createCmd.Flags().BoolVar(&config.KeepOnFailure, "keep-on-failure", false, "keep the image, container, volume and kubeconfig context of an instance which failed to be created, for debugging")
 	deleteCmd.Flags().StringVar(&config.Name, "name", "", "name of simulator instance")
 	deleteCmd.MarkFlagRequired("name")
 	exportCmd.Flags().StringVar(&config.Name, "name", "", "name of simulator instance")
 	exportCmd.MarkFlagRequired("name")
+	logsCmd.Flags().BoolVarP(&logOptions.Follow, "follow", "f", false, "follow log output")
+	logsCmd.Flags().StringVar(&logOptions.Since, "since", "", "show logs since timestamp (e.g. 2024-11-18T04:34:27Z) or relative (e.g. 10m)")
+	logsCmd.Flags().StringVarP(&logOptions.Tail, "tail", "n", "all", "number of lines to show from the end of the logs")
+	logsCmd.Flags().BoolVarP(&logOptions.Timestamps, "timestamps", "t", false, "show timestamps")
+	execCmd.Flags().BoolVarP(&interactive, "stdin", "i", true, "attach stdin to the command")
+	execCmd.Flags().BoolVarP(&tty, "tty", "t", true, "allocate a tty for the command when stdin is a terminal")
+	imagePullCmd.Flags().StringVar(&config.Image, "image", Image, "image to pull")
+	imageSaveCmd.Flags().StringVar(&config.Image, "image", Image, "image to save")
+	imageSaveCmd.Flags().StringVarP(&imageFile, "output", "o", "", "file to write the image archive to")
+	imageSaveCmd.MarkFlagRequired("output")
+	imageLoadCmd.Flags().StringVarP(&imageFile, "input", "i", "", "image archive to load")
+	imageLoadCmd.MarkFlagRequired("input")
+	pruneCmd.Flags().BoolVar(&dryRun, "dry-run", false, "report resources which would be removed without removing them")
+	listCmd.Flags().StringVarP(&listOptions.Output, "output", "o", OutputTable, "output format, one of table, wide, json, yaml, name or custom-columns=HEADER:.field,...")
+	listCmd.Flags().StringVar(&listOptions.SortBy, "sort-by", defaultSortBy, "field to sort instances by, like .name, .port or .metadata.created")
+	listCmd.Flags().BoolVar(&listOptions.NoHeaders, "no-headers", false, "omit column headers from table, wide and custom-columns output")
+	describeCmd.Flags().StringVarP(&output, "output", "o", "", "output format, yaml or json. defaults to a human readable description")
+	tunnelCmd.Flags().IntVar(&localPort, "local-port", 0, "local port to forward to the simulator api server, defaults to a random free port")
+	for _, v := range []*cobra.Command{stopCmd, startCmd, restartCmd, logsCmd, execCmd, shellCmd, tunnelCmd, describeCmd} {
+		v.Flags().StringVar(&config.Name, "name", "", "name of simulator instance")
+		v.MarkFlagRequired("name")
+	}
+	for _, v := range []*cobra.Command{startCmd, restartCmd} {
+		v.Flags().DurationVar(&config.WaitTimeout, "wait-timeout", 5*time.Minute, "time to wait for simulator api server to become ready")
+	}
 
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "sim-cli",
-	Short: "cli to manage simulator instances",
+	Use:     "sim-cli",
+	Version: Version,
+	Short:   "cli to manage simulator instances",
 	Long: `sim-cli is a utility to help create and manage multiple support bundle kid instances in a docker container. 
 This allows users to have multiple copies of support bundle kit running on your desktop to allow debugging of harvester issues`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no sub-command specified")
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// initialise docker client
-		ctx := context.TODO()
-		config.Ctx = ctx
-		dockerClient, err := docker.NewClient(ctx)
-		if err != nil {
-			return fmt.Errorf("error initialising new docker client: %v", err)
-		}
-		config.DockerClient = *dockerClient
 		if verbose {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
+
+		userSettings, err := settings.Load()
+		if err != nil {
+			return err
+		}
+
+		// runtime flag takes precedence over runtime in settings
+		if !cmd.Flags().Changed("runtime") && userSettings.Runtime != "" {
+			runtimeName = userSettings.Runtime
+		}
+
+		// resource limit flags take precedence over default limits in settings
+		if !cmd.Flags().Changed("cpus") && userSettings.CPUs != "" {
+			config.CPUs = userSettings.CPUs.String()
+		}
+		if !cmd.Flags().Changed("memory") && userSettings.Memory != "" {
+			config.Memory = userSettings.Memory
+		}
+
+		// commands are cancelled on interrupt, or once the timeout elapses
+		ctx := cmd.Context()
+		if timeout > 0 {
+			ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		}
+		config.Ctx = ctx
+
+		// initialise container runtime client
+		containerRuntime, err := newRuntime(ctx, runtimeName, clientOptions)
+		if err != nil {
+			return fmt.Errorf("error initialising %s runtime client: %v", runtimeName, err)
+		}
+		config.Runtime = containerRuntime
 		return nil
 	},
 }
@@ -68,7 +171,7 @@ var listCmd = &cobra.Command{
 	Short: "list existing simulator instances",
 	Long:  `list queries the docker daemon to identify currently list of simulator instances`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return config.ListInstances()
+		return config.ListInstances(listOptions, cmd.OutOrStdout())
 	},
 }
 
@@ -82,12 +185,7 @@ var createCmd = &cobra.Command{
 			return err
 		}
 
-		if err := config.CreateNewInstance(); err != nil {
-			return err
-		}
-
-		time.Sleep(10 * time.Second)
-		return config.ExportKubeConfig()
+		return config.Create()
 	},
 }
 
@@ -113,8 +211,163 @@ name of the simulator instance`,
 	},
 }
 
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "stop a support bundle kit simulator instance",
+	Long: `stop a support bundle kit simulator instance without removing it. The container and simulator state are retained,
+and the instance can be started again using the start command`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.StopInstance()
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start",
+	Short: "start a stopped support bundle kit simulator instance",
+	Long: `start a stopped support bundle kit simulator instance and wait for it to become ready. If the simulator is exposed on a
+different port, the kubeconfig context for the instance is updated`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.StartInstance(config.WaitTimeout)
+	},
+}
+
+var restartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "restart a support bundle kit simulator instance",
+	Long:  `restart stops and starts a support bundle kit simulator instance, retaining the container and simulator state`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.RestartInstance(config.WaitTimeout)
+	},
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "fetch logs of a support bundle kit simulator instance",
+	Long:  `fetch logs of the container running a support bundle kit simulator instance, including instances which have exited`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.Logs(logOptions, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec --name NAME -- COMMAND [ARGS...]",
+	Short: "run a command in a support bundle kit simulator instance",
+	Long:  `run a command in the container running a support bundle kit simulator instance, with stdin and a tty attached by default`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		// errors are reported by Execute, which passes through the exit code of the command
+		cmd.SilenceUsage, cmd.SilenceErrors = true, true
+		return runExec(execOptions(args, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(), interactive, tty))
+	},
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "open an interactive shell in a support bundle kit simulator instance",
+	Long:  `open an interactive shell in the container running a support bundle kit simulator instance, using bash if available and sh otherwise`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		// errors are reported by Execute, which passes through the exit code of the command
+		cmd.SilenceUsage, cmd.SilenceErrors = true, true
+		return runExec(execOptions(defaultShell, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(), true, true))
+	},
+}
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "forward a local port to a simulator instance on a remote docker host over ssh",
+	Long: `forward a local port to the api server of a simulator instance running on a docker host accessed over ssh://,
+and point the kubeconfig context for the instance at the forwarded port. The tunnel is kept open until interrupted`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.Tunnel(localPort)
+	},
+}
+
+var imageCmd = &cobra.Command{
+	Use:     "image",
+	Aliases: []string{"images"},
+	Short:   "manage the support bundle kit base image",
+	Long:    `manage the support bundle kit base image used to create simulator instances`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return fmt.Errorf("no sub-command specified")
+	},
+}
+
+var imagePullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "pull the support bundle kit base image",
+	Long:  `pull the support bundle kit base image, using registry credentials from the docker cli config`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.PullImage()
+	},
+}
+
+var imageSaveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "save the support bundle kit base image to a file",
+	Long:  `save the support bundle kit base image to a tar archive, which can be loaded on machines without registry access with sim-cli image load`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.SaveImage(imageFile)
+	},
+}
+
+var imageLoadCmd = &cobra.Command{
+	Use:   "load",
+	Short: "load the support bundle kit base image from a file",
+	Long:  `load the support bundle kit base image from a tar archive generated by sim-cli image save or docker save`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.LoadImage(imageFile)
+	},
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "remove resources left behind by failed or deleted simulator instances",
+	Long: `remove sim-cli managed containers which never started, images and kubeconfig contexts for instances without a container,
+and bundle volumes not used by any instance`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		return config.Prune(dryRun, cmd.OutOrStdout())
+	},
+}
+
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "show details of a support bundle kit simulator instance",
+	Long: `show the state, restart history, port bindings, kubeconfig context, resource limits, image, bundle metadata
+and last few log lines of a support bundle kit simulator instance`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logrus.WithField("config", config).Debug("received config")
+		desc, err := config.Describe()
+		if err != nil {
+			return err
+		}
+		return PrintDescription(desc, output, cmd.OutOrStdout())
+	},
+}
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// interrupting a command cancels its context instead of exiting immediately, so commands can clean up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	stop()
+	if err != nil {
+		// exit code of commands run in the simulator is passed through without further output
+		var exitErr *ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+	"github.com/ibrokethecloud/sim-cli/pkg/kubeconfig"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// OutputJSON and OutputYAML are the machine readable output formats
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+
+	defaultDescribeLogLines = 10
+)
+
+// InstanceDescription is a detailed view of a single simulator instance
+type InstanceDescription struct {
+	Name        string           `json:"name"`
+	ContainerID string           `json:"containerID"`
+	State       InstanceState    `json:"state"`
+	Ports       []PortBinding    `json:"ports"`
+	KubeConfig  KubeConfigInfo   `json:"kubeconfig"`
+	Resources   ResourceLimits   `json:"resources"`
+	Image       ImageInfo        `json:"image"`
+	Metadata    runtime.Metadata `json:"metadata"`
+	Logs        []string         `json:"logs"`
+}
+
+// InstanceState is the state of the simulator container, along with its restart history
+type InstanceState struct {
+	Status       string `json:"status"`
+	ExitCode     int    `json:"exitCode"`
+	OOMKilled    bool   `json:"oomKilled"`
+	Error        string `json:"error,omitempty"`
+	Created      string `json:"created,omitempty"`
+	StartedAt    string `json:"startedAt,omitempty"`
+	FinishedAt   string `json:"finishedAt,omitempty"`
+	RestartCount int    `json:"restartCount"`
+}
+
+// PortBinding is a container port published on the host
+type PortBinding struct {
+	ContainerPort string `json:"containerPort"`
+	HostIP        string `json:"hostIP"`
+	HostPort      string `json:"hostPort"`
+}
+
+// KubeConfigInfo identifies the context for the instance in the simulator kubeconfig
+type KubeConfigInfo struct {
+	Path    string `json:"path"`
+	Context string `json:"context,omitempty"`
+	Server  string `json:"server,omitempty"`
+}
+
+// ResourceLimits are the cpu and memory limits of the simulator container
+type ResourceLimits struct {
+	CPUs   string `json:"cpus"`
+	Memory string `json:"memory"`
+}
+
+// ImageInfo describes the image the simulator container runs
+type ImageInfo struct {
+	Name   string   `json:"name"`
+	ID     string   `json:"id,omitempty"`
+	Size   int64    `json:"size,omitempty"`
+	Layers []string `json:"layers,omitempty"`
+}
+
+// Describe collects the details of the instance. Details which cannot be fetched, for example logs of a
+// container which was never started, are logged and left empty
+func (s *Simulator) Describe() (*InstanceDescription, error) {
+	info, err := s.Runtime.InspectContainer(s.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting instance %s: %w", s.Name, err)
+	}
+
+	var labels map[string]string
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+
+	desc := &InstanceDescription{
+		Name:        s.Name,
+		ContainerID: info.ID,
+		State:       describeState(info),
+		Ports:       describePorts(info),
+		Resources:   describeLimits(runtime.ParseLimits(labels)),
+		Image:       ImageInfo{Name: info.Image},
+		Metadata:    runtime.ParseMetadata(labels),
+		Logs:        []string{},
+	}
+	if info.Config != nil {
+		desc.Image.Name = info.Config.Image
+	}
+
+	image, err := s.Runtime.InspectImage(desc.Image.Name)
+	if err != nil {
+		logrus.WithError(err).Warnf("unable to inspect image for instance %s", s.Name)
+	} else {
+		desc.Image.ID = image.ID
+		desc.Image.Size = image.Size
+		desc.Image.Layers = image.RootFS.Layers
+	}
+
+	kubeConfigPath, err := simKubeConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	desc.KubeConfig.Path = kubeConfigPath
+	server, err := kubeconfig.ServerURL(kubeConfigPath, s.Name)
+	if err != nil {
+		logrus.WithError(err).Warnf("unable to read kubeconfig context for instance %s", s.Name)
+	}
+	if server != "" {
+		desc.KubeConfig.Context = s.Name
+		desc.KubeConfig.Server = server
+	}
+
+	logs, err := s.Runtime.TailLogs(s.Name, defaultDescribeLogLines)
+	if err != nil {
+		logrus.WithError(err).Warnf("unable to fetch logs for instance %s", s.Name)
+	} else if logs = strings.TrimRight(logs, "\n"); logs != "" {
+		desc.Logs = strings.Split(logs, "\n")
+	}
+	return desc, nil
+}
+
+func describeState(info types.ContainerJSON) InstanceState {
+	state := InstanceState{
+		Created:      info.Created,
+		RestartCount: info.RestartCount,
+	}
+	if info.State != nil {
+		state.Status = info.State.Status
+		state.ExitCode = info.State.ExitCode
+		state.OOMKilled = info.State.OOMKilled
+		state.Error = info.State.Error
+		state.StartedAt = info.State.StartedAt
+		state.FinishedAt = info.State.FinishedAt
+	}
+	return state
+}
+
+// describePorts returns the published ports of the container, which are only available while it is running
+func describePorts(info types.ContainerJSON) []PortBinding {
+	ports := []PortBinding{}
+	if info.NetworkSettings == nil {
+		return ports
+	}
+
+	for port, bindings := range info.NetworkSettings.Ports {
+		for _, v := range bindings {
+			ports = append(ports, PortBinding{
+				ContainerPort: string(port),
+				HostIP:        v.HostIP,
+				HostPort:      v.HostPort,
+			})
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].ContainerPort+ports[i].HostIP < ports[j].ContainerPort+ports[j].HostIP
+	})
+	return ports
+}
+
+func describeLimits(nanoCPUs, memory int64) ResourceLimits {
+	cpus, mem := formatLimits(nanoCPUs, memory)
+	return ResourceLimits{CPUs: cpus, Memory: mem}
+}
+
+// PrintDescription writes the description of the instance to out in the output format, or in a human
+// readable form when output is empty
+func PrintDescription(desc *InstanceDescription, output string, out io.Writer) error {
+	switch output {
+	case OutputJSON, OutputYAML:
+		return printObject(desc, output, out)
+	case "":
+		return printDescription(desc, out)
+	default:
+		return fmt.Errorf("unsupported output format %s, supported formats are %s and %s", output, OutputJSON, OutputYAML)
+	}
+}
+
+func printDescription(desc *InstanceDescription, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", desc.Name)
+	fmt.Fprintf(w, "Container ID:\t%s\n", desc.ContainerID)
+	fmt.Fprintf(w, "State:\t%s\n", desc.State.Status)
+	fmt.Fprintf(w, "  Exit Code:\t%d\n", desc.State.ExitCode)
+	if desc.State.OOMKilled {
+		fmt.Fprintf(w, "  OOM Killed:\t%t\n", desc.State.OOMKilled)
+	}
+	if desc.State.Error != "" {
+		fmt.Fprintf(w, "  Error:\t%s\n", desc.State.Error)
+	}
+	fmt.Fprintf(w, "  Created:\t%s\n", valueOrNone(desc.State.Created))
+	fmt.Fprintf(w, "  Started:\t%s\n", valueOrNone(desc.State.StartedAt))
+	fmt.Fprintf(w, "  Finished:\t%s\n", valueOrNone(desc.State.FinishedAt))
+	fmt.Fprintf(w, "  Restart Count:\t%d\n", desc.State.RestartCount)
+
+	if len(desc.Ports) == 0 {
+		fmt.Fprintf(w, "Ports:\t%s\n", valueOrNone(""))
+	} else {
+		fmt.Fprintf(w, "Ports:\t\n")
+	}
+	for _, v := range desc.Ports {
+		fmt.Fprintf(w, "  %s:\t%s:%s\n", v.ContainerPort, v.HostIP, v.HostPort)
+	}
+
+	fmt.Fprintf(w, "KubeConfig:\t%s\n", desc.KubeConfig.Path)
+	fmt.Fprintf(w, "  Context:\t%s\n", valueOrNone(desc.KubeConfig.Context))
+	fmt.Fprintf(w, "  Server:\t%s\n", valueOrNone(desc.KubeConfig.Server))
+	fmt.Fprintf(w, "Resources:\t\n")
+	fmt.Fprintf(w, "  CPUs:\t%s\n", desc.Resources.CPUs)
+	fmt.Fprintf(w, "  Memory:\t%s\n", desc.Resources.Memory)
+	fmt.Fprintf(w, "Image:\t%s\n", desc.Image.Name)
+	fmt.Fprintf(w, "  ID:\t%s\n", valueOrNone(desc.Image.ID))
+	if desc.Image.Size != 0 {
+		fmt.Fprintf(w, "  Size:\t%s\n", units.HumanSize(float64(desc.Image.Size)))
+	}
+	fmt.Fprintf(w, "  Layers:\t%d\n", len(desc.Image.Layers))
+
+	m := desc.Metadata
+	fmt.Fprintf(w, "Bundle:\t%s\n", valueOrNone(m.BundlePath))
+	fmt.Fprintf(w, "  SHA256:\t%s\n", valueOrNone(m.BundleSHA256))
+	if m.BundleSize != 0 {
+		fmt.Fprintf(w, "  Size:\t%s\n", units.HumanSize(float64(m.BundleSize)))
+	}
+	fmt.Fprintf(w, "Metadata:\t\n")
+	fmt.Fprintf(w, "  Schema Version:\t%d\n", m.SchemaVersion)
+	if !m.Created.IsZero() {
+		fmt.Fprintf(w, "  Created:\t%s\n", m.Created.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "  Created By:\t%s\n", valueOrNone(m.CreatedBy))
+	fmt.Fprintf(w, "  Base Image:\t%s\n", valueOrNone(m.BaseImage))
+	fmt.Fprintf(w, "  Base Image Digest:\t%s\n", valueOrNone(m.BaseImageDigest))
+	fmt.Fprintf(w, "  sim-cli Version:\t%s\n", valueOrNone(m.Version))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Logs (last %d lines):\n", defaultDescribeLogLines)
+	for _, v := range desc.Logs {
+		fmt.Fprintf(out, "  %s\n", v)
+	}
+	return nil
+}
+
+// valueOrNone returns v, or None if v is empty, in the same way list reports empty values
+func valueOrNone(v string) string {
+	if v == "" {
+		return "None"
+	}
+	return v
+}
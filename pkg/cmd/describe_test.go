@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_Describe(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.CPUs = "1.5"
+	s.Memory = "4g"
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.ExportKubeConfig())
+	r.Containers[s.Name].RestartCount = 2
+	r.Containers[s.Name].Logs = "line 1\nline 2\n"
+
+	desc, err := s.Describe()
+	assert.NoError(err)
+	assert.Equal(s.Name, desc.Name)
+	assert.Equal(r.Containers[s.Name].ID, desc.ContainerID)
+	assert.Equal("running", desc.State.Status)
+	assert.Equal(2, desc.State.RestartCount)
+	assert.Len(desc.Ports, 1)
+	assert.Equal("6443/tcp", desc.Ports[0].ContainerPort)
+	assert.Equal(s.Name, desc.KubeConfig.Context)
+	assert.Equal(loadSimKubeConfig(t)[s.Name], desc.KubeConfig.Server)
+	assert.Equal(ResourceLimits{CPUs: "1.5", Memory: "4GiB"}, desc.Resources)
+	assert.Equal(r.Images[s.Name].ID, desc.Image.ID)
+	assert.Len(desc.Image.Layers, 2)
+	assert.Equal(s.BundlePath, desc.Metadata.BundlePath)
+	assert.Equal([]string{"line 1", "line 2"}, desc.Logs)
+
+	out := new(bytes.Buffer)
+	assert.NoError(PrintDescription(desc, "", out))
+	assert.Contains(out.String(), "Restart Count:")
+	assert.Contains(out.String(), desc.KubeConfig.Server)
+	assert.Contains(out.String(), "  line 2\n")
+
+	out.Reset()
+	assert.NoError(PrintDescription(desc, OutputJSON, out))
+	decoded := &InstanceDescription{}
+	assert.NoError(json.Unmarshal(out.Bytes(), decoded))
+	assert.Equal(desc.ContainerID, decoded.ContainerID)
+
+	out.Reset()
+	assert.NoError(PrintDescription(desc, OutputYAML, out))
+	decoded = &InstanceDescription{}
+	assert.NoError(yaml.Unmarshal(out.Bytes(), decoded))
+	assert.Equal(desc.Resources, decoded.Resources)
+
+	assert.ErrorContains(PrintDescription(desc, "xml", out), "unsupported output format")
+}
+
+func Test_DescribeStoppedInstance(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.StopInstance())
+	r.Errors["InspectImage"] = errInjected
+	r.Errors["TailLogs"] = errInjected
+
+	desc, err := s.Describe()
+	assert.NoError(err, "expected image and log errors to be ignored")
+	assert.Equal("exited", desc.State.Status)
+	assert.Empty(desc.Ports)
+	assert.Empty(desc.KubeConfig.Context, "expected no context before kubeconfig is exported")
+	assert.Equal(ResourceLimits{CPUs: "unlimited", Memory: "unlimited"}, desc.Resources)
+	assert.Empty(desc.Image.ID)
+	assert.Empty(desc.Logs)
+
+	s.Name = "missing"
+	_, err = s.Describe()
+	assert.Error(err)
+}
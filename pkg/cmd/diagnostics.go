@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInstancesDir = ".sim/instances"
+	crashDirPrefix      = "crash-"
+	crashTimeFormat     = "20060102T150405Z"
+)
+
+// exitedError is returned when the simulator container exits or is removed while waiting for the instance to become ready
+type exitedError struct {
+	name           string
+	removed        bool
+	created        bool
+	exitCode       int
+	oomKilled      bool
+	memory         string
+	diagnosticsDir string
+}
+
+func (e *exitedError) Error() string {
+	if e.removed {
+		return fmt.Sprintf("simulator container for instance %s was removed while waiting for it to become ready", e.name)
+	}
+	msg := fmt.Sprintf("simulator container for instance %s exited unexpectedly with exit code %d", e.name, e.exitCode)
+	switch {
+	case e.created:
+		// containers which never started have no meaningful exit code
+		msg = fmt.Sprintf("simulator container for instance %s was created but never started", e.name)
+	case e.oomKilled:
+		msg = fmt.Sprintf("simulator container for instance %s was killed after running out of memory while loading the bundle", e.name)
+		if e.memory != "" {
+			msg = fmt.Sprintf("%s, memory limit of %s may be too low for the bundle, use --memory to increase it", msg, e.memory)
+		}
+	}
+	if e.diagnosticsDir != "" {
+		msg = fmt.Sprintf("%s, crash diagnostics saved to %s", msg, e.diagnosticsDir)
+	}
+	return msg
+}
+
+// isExited checks if err was caused by the simulator container exiting or being removed
+func isExited(err error) bool {
+	var exited *exitedError
+	return errors.As(err, &exited)
+}
+
+// checkRunning inspects the simulator container, and if it is no longer running captures crash diagnostics
+// before returning an exitedError. An exitedError is also returned if the container has been removed
+func (s *Simulator) checkRunning() error {
+	info, err := s.Runtime.InspectContainer(s.Name)
+	if err != nil {
+		// inspect errors may be transient, so only give up once the container is known to be gone
+		if containers, findErr := s.Runtime.FindContainer(s.Name); findErr == nil && len(containers) == 0 {
+			return &exitedError{name: s.Name, removed: true}
+		}
+		return err
+	}
+
+	if info.State == nil || info.State.Running || info.State.Restarting {
+		return nil
+	}
+
+	exited := &exitedError{
+		name:      s.Name,
+		created:   runtime.State(info.State.Status) == runtime.StateCreated,
+		exitCode:  info.State.ExitCode,
+		oomKilled: info.State.OOMKilled,
+	}
+	if info.Config != nil {
+		if memory, err := strconv.ParseInt(info.Config.Labels[runtime.MemoryLabel], 10, 64); err == nil {
+			exited.memory = units.BytesSize(float64(memory))
+		}
+	}
+
+	dir, err := s.captureDiagnostics(info)
+	if err != nil {
+		logrus.WithError(err).Warnf("error capturing crash diagnostics for instance %s", s.Name)
+	}
+	exited.diagnosticsDir = dir
+	return exited
+}
+
+// captureDiagnostics saves the logs, exit code and inspect output of the simulator container to
+// $HOME/.sim/instances/<name>/crash-<timestamp> and returns the directory
+func (s *Simulator) captureDiagnostics(info types.ContainerJSON) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error fetching home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, defaultInstancesDir, s.Name, crashDirPrefix+time.Now().UTC().Format(crashTimeFormat))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("error creating diagnostics directory %s: %w", dir, err)
+	}
+
+	inspect, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return dir, fmt.Errorf("error marshalling container inspect output: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "inspect.json"), inspect, 0600); err != nil {
+		return dir, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "exit-code"), []byte(fmt.Sprintf("%d\n", info.State.ExitCode)), 0600); err != nil {
+		return dir, err
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(dir, "container.log"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return dir, err
+	}
+	defer logFile.Close()
+
+	if err := s.Runtime.StreamLogs(s.Name, runtime.LogOptions{Timestamps: true}, logFile, logFile); err != nil {
+		return dir, err
+	}
+
+	logrus.Infof("saved crash diagnostics for instance %s to %s", s.Name, dir)
+	return dir, nil
+}
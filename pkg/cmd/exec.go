@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/moby/term"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultShell starts bash if it is available in the simulator container, falling back to sh
+var defaultShell = []string{"sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}
+
+// ExitCodeError is returned when a command run in a simulator container exits with a non zero code
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.Code)
+}
+
+// execOptions returns the options to run cmd with the provided streams. A tty is only allocated
+// when stdin is attached and is a terminal
+func execOptions(cmd []string, stdin io.Reader, stdout, stderr io.Writer, interactive, tty bool) runtime.ExecOptions {
+	opts := runtime.ExecOptions{
+		Cmd:    cmd,
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+
+	if !interactive {
+		return opts
+	}
+	opts.Stdin = stdin
+
+	fd, isTerminal := term.GetFdInfo(stdin)
+	if !tty || !isTerminal {
+		return opts
+	}
+
+	opts.TTY = true
+	if size, err := term.GetWinsize(fd); err == nil {
+		opts.Height, opts.Width = uint(size.Height), uint(size.Width)
+	}
+	return opts
+}
+
+// runExec runs the command in the simulator container, placing the terminal in raw mode
+// for the duration of the command when a tty is allocated
+func runExec(opts runtime.ExecOptions) error {
+	if opts.TTY {
+		fd, _ := term.GetFdInfo(opts.Stdin)
+		state, err := term.SetRawTerminal(fd)
+		if err != nil {
+			return fmt.Errorf("error setting terminal to raw mode: %w", err)
+		}
+		defer func() {
+			if err := term.RestoreTerminal(fd, state); err != nil {
+				logrus.WithError(err).Warn("error restoring terminal")
+			}
+		}()
+	}
+	return config.Exec(opts)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_execOptions(t *testing.T) {
+	assert := require.New(t)
+	stdin := strings.NewReader("")
+	opts := execOptions([]string{"sh"}, stdin, io.Discard, io.Discard, true, true)
+	assert.Equal(stdin, opts.Stdin)
+	assert.False(opts.TTY, "expected no tty when stdin is not a terminal")
+
+	opts = execOptions([]string{"sh"}, stdin, io.Discard, io.Discard, false, true)
+	assert.Nil(opts.Stdin)
+	assert.False(opts.TTY)
+}
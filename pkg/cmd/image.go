@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ensureBaseImage pulls the base image according to the pull policy, and ensures it is present before it
+// is used to create an instance
+func (s *Simulator) ensureBaseImage() error {
+	if s.PullPolicy == PullAlways {
+		if err := s.PullImage(); err != nil {
+			return s.missingImageError(err)
+		}
+		return nil
+	}
+
+	exists, err := s.Runtime.ImageExists(s.Image)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	if s.PullPolicy == PullNever {
+		return s.missingImageError(fmt.Errorf("base image %s is not present and pull policy is %s", s.Image, PullNever))
+	}
+
+	if err := s.PullImage(); err != nil {
+		return s.missingImageError(err)
+	}
+	return nil
+}
+
+// missingImageError suggests loading the base image from a file when it cannot be pulled, for
+// example in air-gapped environments
+func (s *Simulator) missingImageError(err error) error {
+	return fmt.Errorf("%w\nif the registry is not reachable, save the image on a machine with registry access with "+
+		"'sim-cli image save --image %[2]s -o sbk.tar' and load it with 'sim-cli image load -i sbk.tar'", err, s.Image)
+}
+
+// PullImage pulls the base image used by simulator instances
+func (s *Simulator) PullImage() error {
+	return s.Runtime.PullImage(s.Image)
+}
+
+// SaveImage exports the base image used by simulator instances to the file output. The image is written
+// to a temporary file which is renamed once complete, to avoid leaving a partial archive behind on failure
+func (s *Simulator) SaveImage(output string) error {
+	f, err := os.CreateTemp(filepath.Dir(output), ".sim-cli-image-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file for image %s: %w", s.Image, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := s.Runtime.SaveImage(s.Image, f); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing image %s to %s: %w", s.Image, output, err)
+	}
+
+	if err := os.Rename(f.Name(), output); err != nil {
+		return fmt.Errorf("error writing image %s to %s: %w", s.Image, output, err)
+	}
+	logrus.Infof("saved image %s to %s", s.Image, output)
+	return nil
+}
+
+// LoadImage imports images from the file input, generated by SaveImage or docker save
+func (s *Simulator) LoadImage(input string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("error opening image archive %s: %w", input, err)
+	}
+	defer f.Close()
+
+	if err := s.Runtime.LoadImage(f); err != nil {
+		return err
+	}
+	logrus.Infof("loaded images from %s", input)
+	return nil
+}
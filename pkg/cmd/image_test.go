@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime/fake"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ensureBaseImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		present     bool
+		expectPull  bool
+		expectError bool
+	}{
+		{name: "always pulls present image", policy: PullAlways, present: true, expectPull: true},
+		{name: "missing pulls absent image", policy: PullMissing, expectPull: true},
+		{name: "missing skips present image", policy: PullMissing, present: true},
+		{name: "never skips present image", policy: PullNever, present: true},
+		{name: "never fails for absent image", policy: PullNever, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			s.PullPolicy = tt.policy
+			r.BaseImages[s.Image] = tt.present
+			err := s.ensureBaseImage()
+			if tt.expectError {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.expectPull, pulled(r))
+			assert.True(r.BaseImages[s.Image])
+		})
+	}
+}
+
+func Test_CreateNewInstancePullFailure(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	r.Errors["PullImage"] = errInjected
+	assert.ErrorIs(s.CreateNewInstance(), errInjected)
+	assert.Empty(r.Containers, "expected no container to be created")
+}
+
+// pulled checks if the base image was pulled
+func pulled(r *fake.Runtime) bool {
+	for _, v := range r.Calls {
+		if v == "PullImage" {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_missingImageSuggestsLoad(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.PullPolicy = PullNever
+	assert.ErrorContains(s.CreateNewInstance(), "sim-cli image load -i sbk.tar")
+	assert.Empty(r.Images, "expected no image to be built")
+
+	s.PullPolicy = PullMissing
+	r.Errors["PullImage"] = errInjected
+	err := s.CreateNewInstance()
+	assert.ErrorIs(err, errInjected)
+	assert.ErrorContains(err, "sim-cli image load -i sbk.tar")
+}
+
+func Test_SaveLoadImage(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	output := filepath.Join(t.TempDir(), "sbk.tar")
+	assert.Error(s.SaveImage(output), "expected error for missing image")
+	assert.NoFileExists(output, "expected no partial archive")
+	entries, err := os.ReadDir(filepath.Dir(output))
+	assert.NoError(err)
+	assert.Empty(entries, "expected temporary file to be removed")
+
+	r.BaseImages[s.Image] = true
+	assert.NoError(s.SaveImage(output))
+	assert.FileExists(output)
+
+	delete(r.BaseImages, s.Image)
+	assert.NoError(s.LoadImage(output))
+	assert.True(r.BaseImages[s.Image])
+	assert.NoError(s.CreateNewInstance())
+	assert.False(pulled(r), "expected loaded image to be used")
+
+	assert.Error(s.LoadImage(filepath.Join(t.TempDir(), "missing.tar")))
+}
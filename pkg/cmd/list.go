@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bndr/gotabulate"
+	"github.com/docker/go-units"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OutputTable and OutputWide are grid tables, with wide adding the container id, base image and bundle size
+	OutputTable = "table"
+	OutputWide  = "wide"
+	// OutputName prints the name of each instance on a separate line
+	OutputName = "name"
+	// OutputCustomColumns is followed by a comma separated list of HEADER:.field column specs
+	OutputCustomColumns = "custom-columns="
+
+	defaultSortBy = "name"
+)
+
+// ListOptions control how instances are reported by list
+type ListOptions struct {
+	// Output is the output format, one of table, wide, json, yaml, name or custom-columns=...
+	Output string
+	// SortBy is the field instances are sorted by, like .name or .metadata.created
+	SortBy string
+	// NoHeaders omits column headers from table, wide and custom-columns output
+	NoHeaders bool
+}
+
+// column is a column of table output
+type column struct {
+	header string
+	value  func(runtime.Instance) string
+}
+
+var tableColumns = []column{
+	{header: "name", value: func(i runtime.Instance) string { return i.Name }},
+	{header: "bundlePath", value: func(i runtime.Instance) string { return i.BundlePath }},
+	{header: "image", value: func(i runtime.Instance) string { return i.Image }},
+	{header: "state", value: func(i runtime.Instance) string { return string(i.State) }},
+	{header: "status", value: func(i runtime.Instance) string { return i.Status }},
+	{header: "bind address", value: func(i runtime.Instance) string { return i.BindAddress }},
+	{header: "exposed port", value: func(i runtime.Instance) string {
+		if i.Port == 0 {
+			return ""
+		}
+		return strconv.Itoa(i.Port)
+	}},
+	{header: "cpus", value: func(i runtime.Instance) string {
+		cpus, _ := formatLimits(i.NanoCPUs, i.Memory)
+		return cpus
+	}},
+	{header: "memory", value: func(i runtime.Instance) string {
+		_, memory := formatLimits(i.NanoCPUs, i.Memory)
+		return memory
+	}},
+	// instances created by older versions of sim-cli have no metadata, which is reported as None
+	{header: "created", value: func(i runtime.Instance) string {
+		if i.Metadata.Created.IsZero() {
+			return ""
+		}
+		return i.Metadata.Created.Local().Format(time.DateTime)
+	}},
+	{header: "created by", value: func(i runtime.Instance) string { return i.Metadata.CreatedBy }},
+	{header: "version", value: func(i runtime.Instance) string { return i.Metadata.Version }},
+	{header: "bundle sha256", value: func(i runtime.Instance) string {
+		if len(i.Metadata.BundleSHA256) > 12 {
+			return i.Metadata.BundleSHA256[:12]
+		}
+		return i.Metadata.BundleSHA256
+	}},
+}
+
+var wideColumns = append(tableColumns[:len(tableColumns):len(tableColumns)], []column{
+	{header: "container id", value: func(i runtime.Instance) string {
+		if len(i.ContainerID) > 12 {
+			return i.ContainerID[:12]
+		}
+		return i.ContainerID
+	}},
+	{header: "base image", value: func(i runtime.Instance) string { return i.Metadata.BaseImage }},
+	{header: "bundle size", value: func(i runtime.Instance) string {
+		if i.Metadata.BundleSize == 0 {
+			return ""
+		}
+		return units.HumanSize(float64(i.Metadata.BundleSize))
+	}},
+}...)
+
+// customColumn is a column of custom-columns output, with the value read from the field of the instance at path
+type customColumn struct {
+	header string
+	path   []string
+}
+
+// ListInstances will report the details of all sim instances, including stopped instances, in the output format
+func (s *Simulator) ListInstances(opts ListOptions, out io.Writer) error {
+	if opts.SortBy == "" {
+		opts.SortBy = defaultSortBy
+	}
+	sortBy, err := parseFieldPath(opts.SortBy)
+	if err != nil {
+		return fmt.Errorf("error parsing sort-by: %w", err)
+	}
+
+	var customColumns []customColumn
+	switch {
+	case opts.Output == "", opts.Output == OutputTable, opts.Output == OutputWide, opts.Output == OutputJSON,
+		opts.Output == OutputYAML, opts.Output == OutputName:
+	case strings.HasPrefix(opts.Output, OutputCustomColumns):
+		customColumns, err = parseCustomColumns(strings.TrimPrefix(opts.Output, OutputCustomColumns))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported output format %s, supported formats are %s, %s, %s, %s, %s and %sHEADER:.field,...",
+			opts.Output, OutputTable, OutputWide, OutputJSON, OutputYAML, OutputName, OutputCustomColumns)
+	}
+
+	instances, err := s.Runtime.FindAllSimManagedInstances()
+	if err != nil {
+		return fmt.Errorf("error listing instances: %w", err)
+	}
+	if err := sortInstances(instances, sortBy); err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Output == OutputJSON, opts.Output == OutputYAML:
+		return printObject(instances, opts.Output, out)
+	case opts.Output == OutputName:
+		for _, v := range instances {
+			fmt.Fprintln(out, v.Name)
+		}
+		return nil
+	case customColumns != nil:
+		return printCustomColumns(instances, customColumns, opts.NoHeaders, out)
+	case opts.Output == OutputWide:
+		return printTable(instances, wideColumns, opts.NoHeaders, out)
+	default:
+		return printTable(instances, tableColumns, opts.NoHeaders, out)
+	}
+}
+
+// printTable renders instances as a grid table
+func printTable(instances []runtime.Instance, columns []column, noHeaders bool, out io.Writer) error {
+	var results [][]interface{}
+	for _, v := range instances {
+		row := make([]interface{}, 0, len(columns))
+		for _, c := range columns {
+			row = append(row, c.value(v))
+		}
+		results = append(results, row)
+	}
+
+	// gotabulate does no handle empty table and panics
+	// so for now we send an empty row if there is nothing returned
+	if len(results) == 0 {
+		results = append(results, make([]interface{}, len(columns)))
+		for i := range results[0] {
+			results[0][i] = ""
+		}
+	}
+
+	headers := make([]string, 0, len(columns))
+	for _, c := range columns {
+		headers = append(headers, c.header)
+	}
+	table := gotabulate.Create(results)
+	table.SetHeaders(headers)
+	table.SetEmptyString("None")
+	table.SetAlign("right")
+	table.SetMaxCellSize(40)
+	table.SetWrapStrings(true)
+	rendered := table.Render("grid")
+
+	// gotabulate always renders headers, which are the single line after the top border followed by the header border
+	if noHeaders {
+		lines := strings.SplitAfter(rendered, "\n")
+		rendered = lines[0] + strings.Join(lines[3:], "")
+	}
+	_, err := fmt.Fprintln(out, rendered)
+	return err
+}
+
+// printCustomColumns renders instances as aligned columns with the values of the requested fields
+func printCustomColumns(instances []runtime.Instance, columns []customColumn, noHeaders bool, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	if !noHeaders {
+		headers := make([]string, 0, len(columns))
+		for _, c := range columns {
+			headers = append(headers, c.header)
+		}
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+	}
+
+	for _, v := range instances {
+		fields, err := instanceFields(v)
+		if err != nil {
+			return err
+		}
+		values := make([]string, 0, len(columns))
+		for _, c := range columns {
+			values = append(values, formatField(lookupField(fields, c.path)))
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	return w.Flush()
+}
+
+// parseCustomColumns parses column specs of the form HEADER:.field, separated by commas
+func parseCustomColumns(spec string) ([]customColumn, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("custom-columns format requires at least one HEADER:.field column")
+	}
+
+	var columns []customColumn
+	for _, v := range strings.Split(spec, ",") {
+		header, field, ok := strings.Cut(v, ":")
+		if !ok || header == "" {
+			return nil, fmt.Errorf("invalid custom column %s, expected HEADER:.field", v)
+		}
+		path, err := parseFieldPath(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom column %s: %w", v, err)
+		}
+		columns = append(columns, customColumn{header: header, path: path})
+	}
+	return columns, nil
+}
+
+// parseFieldPath parses a field of an instance, as named in its json or yaml output, like .metadata.version.
+// A leading dot and the {} of kubectl style json paths are optional
+func parseFieldPath(field string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(field, "{"), "}"), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("empty field")
+	}
+
+	path := strings.Split(trimmed, ".")
+	t := reflect.TypeOf(runtime.Instance{})
+	for _, name := range path {
+		var f reflect.StructField
+		ok := t.Kind() == reflect.Struct
+		if ok {
+			f, ok = jsonField(t, name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown field %s", field)
+		}
+		t = f.Type
+	}
+	return path, nil
+}
+
+// jsonField returns the field of struct type t with the json name
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// instanceFields returns the fields of the instance as they appear in json output
+func instanceFields(instance runtime.Instance) (map[string]interface{}, error) {
+	contents, err := json.Marshal(instance)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding instance %s: %w", instance.Name, err)
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding instance %s: %w", instance.Name, err)
+	}
+	return fields, nil
+}
+
+// lookupField returns the value at path in fields, or nil if it is not set
+func lookupField(fields map[string]interface{}, path []string) interface{} {
+	var value interface{} = fields
+	for _, v := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[v]
+	}
+	return value
+}
+
+// formatField returns a field value for column output, with empty values reported as None like table output
+func formatField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "None"
+	case string:
+		if v == "" {
+			return "None"
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		contents, _ := json.Marshal(v)
+		return string(contents)
+	}
+}
+
+// sortInstances sorts instances by the field at path. Numeric fields are sorted numerically, all other
+// fields by their string value, and instances with the same value are sorted by name
+func sortInstances(instances []runtime.Instance, path []string) error {
+	values := make([]interface{}, len(instances))
+	for i, v := range instances {
+		fields, err := instanceFields(v)
+		if err != nil {
+			return err
+		}
+		values[i] = lookupField(fields, path)
+	}
+
+	indexes := make([]int, len(instances))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := values[indexes[i]], values[indexes[j]]
+		x, xok := a.(float64)
+		y, yok := b.(float64)
+		if xok && yok {
+			if x != y {
+				return x < y
+			}
+		} else if x, y := formatField(a), formatField(b); x != y {
+			return x < y
+		}
+		return instances[indexes[i]].Name < instances[indexes[j]].Name
+	})
+
+	sorted := make([]runtime.Instance, 0, len(instances))
+	for _, i := range indexes {
+		sorted = append(sorted, instances[i])
+	}
+	copy(instances, sorted)
+	return nil
+}
+
+// formatLimits returns the cpu and memory limits in human readable form, or unlimited if no limit was applied
+func formatLimits(nanoCPUs, memory int64) (string, string) {
+	cpus, mem := "unlimited", "unlimited"
+	if nanoCPUs > 0 {
+		cpus = strconv.FormatFloat(float64(nanoCPUs)/1e9, 'f', -1, 64)
+	}
+	if memory > 0 {
+		mem = units.BytesSize(float64(memory))
+	}
+	return cpus, mem
+}
+
+// printObject writes v to out in json or yaml
+func printObject(v interface{}, output string, out io.Writer) error {
+	switch output {
+	case OutputJSON:
+		contents, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error generating json output: %w", err)
+		}
+		_, err = fmt.Fprintln(out, string(contents))
+		return err
+	case OutputYAML:
+		contents, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("error generating yaml output: %w", err)
+		}
+		_, err = out.Write(contents)
+		return err
+	default:
+		return fmt.Errorf("unsupported output format %s", output)
+	}
+}
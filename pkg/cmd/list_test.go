@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime/fake"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+// newTestInstances creates a running instance, and a stopped instance with resource limits
+func newTestInstances(t *testing.T) *Simulator {
+	t.Helper()
+	assert := require.New(t)
+	s, _ := newTestSimulator(t)
+	s.Name = "issue-7007"
+	assert.NoError(s.CreateNewInstance())
+
+	s.Name = "issue-113"
+	s.CPUs = "1.5"
+	s.Memory = "4g"
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.StopInstance())
+	return s
+}
+
+func Test_ListInstances(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     ListOptions
+		expected string
+	}{
+		{
+			name:     "name output sorted by name",
+			opts:     ListOptions{Output: OutputName},
+			expected: "issue-113\nissue-7007\n",
+		},
+		{
+			name:     "name output sorted by numeric field",
+			opts:     ListOptions{Output: OutputName, SortBy: ".nanoCPUs"},
+			expected: "issue-7007\nissue-113\n",
+		},
+		{
+			name:     "custom columns",
+			opts:     ListOptions{Output: "custom-columns=NAME:.name,STATE:.state,CPUS:.nanoCPUs,BIND ADDRESS:{.bindAddress}", SortBy: "state"},
+			expected: "NAME         STATE     CPUS         BIND ADDRESS\nissue-113    exited    1500000000   None\nissue-7007   running   0            None\n",
+		},
+		{
+			name:     "custom columns without headers",
+			opts:     ListOptions{Output: "custom-columns=NAME:.name,BASE IMAGE:.metadata.baseImage", NoHeaders: true},
+			expected: "issue-113    rancher/support-bundle-kit:dev\nissue-7007   rancher/support-bundle-kit:dev\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestInstances(t)
+			out := new(bytes.Buffer)
+			require.NoError(t, s.ListInstances(tt.opts, out))
+			require.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func Test_ListInstancesTable(t *testing.T) {
+	assert := require.New(t)
+	s := newTestInstances(t)
+
+	out := new(bytes.Buffer)
+	assert.NoError(s.ListInstances(ListOptions{}, out))
+	assert.Contains(out.String(), "exposed port")
+	// resource limits and metadata are part of the default table
+	for _, header := range []string{"cpus", "memory", "created", "created by", "version", "bundle sha256"} {
+		assert.Contains(out.String(), header)
+	}
+	assert.Contains(out.String(), "4GiB")
+	assert.Contains(out.String(), testBundleDigest[:12])
+	assert.NotContains(out.String(), "container id")
+	assert.Less(strings.Index(out.String(), "issue-113"), strings.Index(out.String(), "issue-7007"))
+
+	out.Reset()
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputWide}, out))
+	assert.Contains(out.String(), "memory")
+	assert.Contains(out.String(), "container id")
+	assert.Contains(out.String(), "base image")
+	assert.Contains(out.String(), "bundle size")
+
+	out.Reset()
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputWide, NoHeaders: true}, out))
+	assert.NotContains(out.String(), "memory")
+	assert.True(strings.HasPrefix(out.String(), "+-"), "expected table to start with top border")
+	assert.Contains(out.String(), "4GiB")
+
+	// empty tables are rendered with a placeholder row
+	s, _ = newTestSimulator(t)
+	out.Reset()
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputTable}, out))
+	assert.Contains(out.String(), "exposed port")
+}
+
+func Test_ListInstancesStructured(t *testing.T) {
+	assert := require.New(t)
+	s := newTestInstances(t)
+
+	out := new(bytes.Buffer)
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputJSON}, out))
+	var instances []runtime.Instance
+	assert.NoError(json.Unmarshal(out.Bytes(), &instances))
+	assert.Len(instances, 2)
+	assert.Equal("issue-113", instances[0].Name)
+	assert.Equal(int64(4294967296), instances[0].Memory)
+	assert.Equal(runtime.StateExited, instances[0].State)
+
+	out.Reset()
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputYAML, SortBy: ".memory"}, out))
+	instances = nil
+	assert.NoError(yaml.Unmarshal(out.Bytes(), &instances))
+	assert.Len(instances, 2)
+	assert.Equal("issue-7007", instances[0].Name, "expected instances sorted by memory limit")
+
+	s, _ = newTestSimulator(t)
+	out.Reset()
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputJSON}, out))
+	assert.Equal("[]\n", out.String())
+}
+
+func Test_ListInstancesInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ListOptions
+	}{
+		{name: "unsupported output", opts: ListOptions{Output: "xml"}},
+		{name: "empty custom columns", opts: ListOptions{Output: "custom-columns="}},
+		{name: "custom column without field", opts: ListOptions{Output: "custom-columns=NAME"}},
+		{name: "custom column with unknown field", opts: ListOptions{Output: "custom-columns=NAME:.missing"}},
+		{name: "custom column with field of scalar", opts: ListOptions{Output: "custom-columns=NAME:.name.first"}},
+		{name: "unknown sort field", opts: ListOptions{SortBy: ".metadata.missing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, r := newTestSimulator(t)
+			require.Error(t, s.ListInstances(tt.opts, new(bytes.Buffer)))
+			require.NotContains(t, r.Calls, "FindAllSimManagedInstances", "expected options to be validated before listing instances")
+		})
+	}
+}
+
+func Test_formatLimits(t *testing.T) {
+	assert := require.New(t)
+	cpus, memory := formatLimits(0, 0)
+	assert.Equal("unlimited", cpus)
+	assert.Equal("unlimited", memory)
+
+	cpus, memory = formatLimits(1500000000, 4294967296)
+	assert.Equal("1.5", cpus)
+	assert.Equal("4GiB", memory)
+}
+
+func Test_ListInstancesStates(t *testing.T) {
+	assert := require.New(t)
+	s := newTestInstances(t)
+	r := s.Runtime.(*fake.Runtime)
+	for _, name := range []string{"issue-created", "issue-restarting", "issue-no-port"} {
+		s.Name = name
+		assert.NoError(s.CreateNewInstance())
+	}
+	r.Containers["issue-created"].Running = false
+	r.Containers["issue-created"].State = "created"
+	r.Containers["issue-restarting"].State = "restarting"
+	r.Containers["issue-restarting"].Unpublished = true
+	r.Containers["issue-no-port"].Unpublished = true
+
+	out := new(bytes.Buffer)
+	assert.NoError(s.ListInstances(ListOptions{Output: "custom-columns=NAME:.name,STATE:.state", NoHeaders: true}, out))
+	assert.Equal(`issue-113          exited
+issue-7007         running
+issue-created      created
+issue-no-port      missing-port
+issue-restarting   restarting
+`, out.String())
+
+	out.Reset()
+	assert.NoError(s.ListInstances(ListOptions{Output: OutputWide}, out), "expected instances without published ports to be listed")
+	assert.Contains(out.String(), "missing-port")
+}
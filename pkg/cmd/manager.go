@@ -2,35 +2,52 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/ibrokethecloud/sim-cli/pkg/docker"
 	"github.com/ibrokethecloud/sim-cli/pkg/kubeconfig"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	defaultSimKubeConfigPath = ".sim/admin.kubeconfig"
 	defaultKubeConfigPath    = "/root/.sim/admin.kubeconfig"
+	defaultPollInterval      = 2 * time.Second
+	defaultProgressInterval  = 15 * time.Second
+	defaultReadyzTimeout     = 5 * time.Second
+	defaultFailureLogLines   = 20
 )
 
 // PreFlightChecks ensures that
 func (s *Simulator) PreFlightChecks() error {
-	// check bundlePath exists
-	bundleInfo, err := os.Stat(s.BundlePath)
-	if err != nil {
-		return fmt.Errorf("error checking bundle path %s: %w", s.BundlePath, err)
+	if s.Mode != ModeImage && s.Mode != ModeMount {
+		return fmt.Errorf("unsupported mode %s, supported modes are %s and %s", s.Mode, ModeImage, ModeMount)
 	}
 
-	// ensure bundlePath is not a directory
-	if bundleInfo.IsDir() {
-		return fmt.Errorf("bundlePath needs to be location of zip file, current path %s is a directory", s.BundlePath)
+	if s.PullPolicy != PullAlways && s.PullPolicy != PullMissing && s.PullPolicy != PullNever {
+		return fmt.Errorf("unsupported pull policy %s, supported policies are %s, %s and %s", s.PullPolicy, PullAlways, PullMissing, PullNever)
 	}
 
-	// check if a container is already running
-	containers, err := s.DockerClient.FindRunningContainer(s.Name)
+	if _, _, err := s.resourceLimits(); err != nil {
+		return err
+	}
+
+	// check bundlePath exists and is a supported bundle format
+	format, err := docker.DetectBundleFormat(s.BundlePath)
 	if err != nil {
-		return fmt.Errorf("error listing running containers: %w", err)
+		return err
+	}
+	logrus.Debugf("using %s bundle %s", format, s.BundlePath)
+
+	// check if a container already exists, stopped containers are retained and still use the name
+	containers, err := s.Runtime.FindContainer(s.Name)
+	if err != nil {
+		return fmt.Errorf("error listing containers: %w", err)
 	}
 
 	if len(containers) != 0 {
@@ -38,7 +55,7 @@ func (s *Simulator) PreFlightChecks() error {
 		for _, v := range containers {
 			ids = append(ids, v.ID)
 		}
-		return fmt.Errorf("found containers with ID's %v already running, please stop existing containers or use a different name argument", ids)
+		return fmt.Errorf("found containers with ID's %v already exist, please delete existing instance or use a different name argument", ids)
 	}
 
 	return nil
@@ -46,52 +63,217 @@ func (s *Simulator) PreFlightChecks() error {
 
 // CreateNewInstall will deploy a new instance of the simulator using the support bundle
 func (s *Simulator) CreateNewInstance() error {
-	if err := s.DockerClient.CreateImage(s.Name, s.BundlePath, s.Image); err != nil {
-		return fmt.Errorf("error creating new sim image: %w", err)
+	hostPort, err := s.selectPort()
+	if err != nil {
+		return err
+	}
+
+	nanoCPUs, memory, err := s.resourceLimits()
+	if err != nil {
+		return err
 	}
 
+	if err := s.ensureBaseImage(); err != nil {
+		return err
+	}
+
+	metadata, err := s.instanceMetadata()
+	if err != nil {
+		return err
+	}
+
+	opts := runtime.RunOptions{
+		InstanceName: s.Name,
+		BundlePath:   s.BundlePath,
+		Metadata:     metadata,
+		BindAddress:  s.BindAddress,
+		HostPort:     hostPort,
+		NanoCPUs:     nanoCPUs,
+		Memory:       memory,
+	}
+
+	switch s.Mode {
+	case ModeMount:
+		volume, err := s.Runtime.CreateBundleVolume(s.BundlePath, metadata.BundleSHA256, s.Image)
+		if err != nil {
+			return fmt.Errorf("error creating bundle volume: %w", err)
+		}
+		s.tx.record(fmt.Sprintf("bundle volume %s", volume), func(r runtime.Runtime) error { return removeUnusedVolume(r, volume) })
+		opts.Image = s.Image
+		opts.Volume = volume
+	default:
+		if err := s.Runtime.CreateImage(s.Name, s.BundlePath, metadata.BundleSHA256, s.Image, metadata.Labels()); err != nil {
+			return fmt.Errorf("error creating new sim image: %w", err)
+		}
+		s.tx.record(fmt.Sprintf("image for instance %s", s.Name), func(r runtime.Runtime) error { return r.RemoveImages(s.Name) })
+	}
+
+	// recorded before running as the container may have been created even if it failed to start
+	s.tx.record(fmt.Sprintf("container for instance %s", s.Name), func(r runtime.Runtime) error { return r.RemoveContainer(s.Name) })
 	//run newly create image
-	if err := s.DockerClient.RunContainer(s.Name, s.BundlePath); err != nil {
+	if err := s.Runtime.RunContainer(opts); err != nil {
 		return fmt.Errorf("error running new image: %w", err)
 	}
 
-	containers, err := s.DockerClient.FindRunningContainer(s.Name)
+	containers, err := s.Runtime.FindRunningContainer(s.Name)
 	if err != nil {
 		return fmt.Errorf("error listing running containers: %w", err)
 	}
 
 	if len(containers) != 1 {
+		// report why the container is not running, such as exiting while starting
+		if err := s.checkRunning(); err != nil {
+			return err
+		}
 		return fmt.Errorf("expected to find only 1 running container but found %d", len(containers))
 	}
 
-	s.Port = int(containers[0].Ports[0].PublicPort)
+	mapping, ok := runtime.PublishedPort(containers[0].Ports)
+	if !ok {
+		return fmt.Errorf("simulator container for instance %s is in state %s: %w", s.Name, runtime.ContainerState(containers[0]), runtime.ErrNoPublishedPort)
+	}
+	s.Port = int(mapping.PublicPort)
 	logrus.WithField("name", s.Name).Infof("simulator instance exposed on port %d", s.Port)
 	return nil
 }
 
-// ListInstances will report the details of currently running sim instances
-func (s *Simulator) ListInstances() error {
-	return s.DockerClient.FindAllSimManagedInstances()
+// WaitForReady polls the simulator instance until the kubeconfig has been generated in the container and the
+// api server reports ready via the merged context. If the instance does not become ready before timeout then
+// the last few lines of container logs are reported in the error
+func (s *Simulator) WaitForReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	if err := s.waitForKubeConfig(deadline); err != nil {
+		return err
+	}
+
+	if err := s.ExportKubeConfig(); err != nil {
+		return err
+	}
+
+	return s.waitForAPIServer(deadline)
+}
+
+// waitForKubeConfig polls the simulator container until the kubeconfig has been generated
+func (s *Simulator) waitForKubeConfig(deadline time.Time) error {
+	err := s.pollUntil(deadline, "kubeconfig to be generated", func() error {
+		if err := s.checkRunning(); err != nil {
+			return err
+		}
+		contents, err := s.Runtime.ReadFile(s.Name, defaultKubeConfigPath)
+		if err != nil {
+			return err
+		}
+		if len(contents) == 0 {
+			return fmt.Errorf("kubeconfig %s is empty", defaultKubeConfigPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return s.readinessError(err)
+	}
+	return nil
+}
+
+// waitForAPIServer polls the readyz endpoint of the simulator api server via the merged context
+func (s *Simulator) waitForAPIServer(deadline time.Time) error {
+	kubeConfigPath, err := simKubeConfigPath()
+	if err != nil {
+		return err
+	}
+
+	err = s.pollUntil(deadline, "api server to be ready", func() error {
+		if err := s.checkRunning(); err != nil {
+			return err
+		}
+		return kubeconfig.CheckReadyz(kubeConfigPath, s.Name, defaultReadyzTimeout)
+	})
+	if err != nil {
+		return s.readinessError(err)
+	}
+	logrus.Infof("simulator instance %s is ready", s.Name)
+	return nil
 }
 
+// pollUntil invokes check every defaultPollInterval until it succeeds, the simulator container exits or is removed,
+// or the deadline is reached, and periodically reports progress while waiting
+func (s *Simulator) pollUntil(deadline time.Time, phase string, check func() error) error {
+	logrus.Infof("waiting for %s", phase)
+	start := time.Now()
+	lastReport := start
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+
+		// no point waiting any further once the container has exited or been removed
+		if isExited(err) {
+			return err
+		}
+		logrus.WithError(err).Debugf("still waiting for %s", phase)
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s: %w", phase, err)
+		}
+
+		if time.Since(lastReport) >= defaultProgressInterval {
+			logrus.Infof("still waiting for %s, elapsed %s", phase, time.Since(start).Round(time.Second))
+			lastReport = time.Now()
+		}
+
+		select {
+		case <-s.Ctx.Done():
+			return fmt.Errorf("interrupted while waiting for %s: %w", phase, s.Ctx.Err())
+		case <-time.After(defaultPollInterval):
+		}
+	}
+}
+
+// readinessError wraps err with the last few lines of logs from the simulator container to help identify
+// why the instance failed to become ready
+func (s *Simulator) readinessError(err error) error {
+	logs, logErr := s.Runtime.TailLogs(s.Name, defaultFailureLogLines)
+	if logErr != nil {
+		logrus.WithError(logErr).Warnf("unable to fetch logs for instance %s", s.Name)
+		return fmt.Errorf("instance %s is not ready: %w", s.Name, err)
+	}
+	return fmt.Errorf("instance %s is not ready: %w\nlast %d lines of container logs:\n%s", s.Name, err, defaultFailureLogLines, logs)
+}
+
+// ExportKubeConfig adds a context for the instance to the simulator kubeconfig. The instance must be running
+// with the api server port published
 func (s *Simulator) ExportKubeConfig() error {
 	logrus.Infof("exporting kubeconfig for instance %s", s.Name)
-	home, err := os.UserHomeDir()
+	instance, err := s.findInstance()
 	if err != nil {
-		return fmt.Errorf("error fetching home directory: %w", err)
+		return err
 	}
 
-	kubeConfigPath := filepath.Join(home, defaultSimKubeConfigPath)
+	if state := runtime.ContainerState(instance); state != runtime.StateRunning {
+		return fmt.Errorf("instance %s is not running (state: %s): %w", s.Name, state, runtime.ErrNoPublishedPort)
+	}
 
-	contents, err := s.DockerClient.ReadFile(s.Name, defaultKubeConfigPath)
+	endpoint, port, err := s.Runtime.QueryExposedMapping(s.Name)
 	if err != nil {
-		return fmt.Errorf("error fetching kubeconfig from container %s: %w", s.Name, err)
+		return err
 	}
+	return s.exportKubeConfig(endpoint, port)
+}
 
-	endpoint, port, err := s.DockerClient.QueryExposedMapping(s.Name)
+// exportKubeConfig adds a context for the instance to the simulator kubeconfig, pointing at the api server on endpoint and port
+func (s *Simulator) exportKubeConfig(endpoint, port string) error {
+	kubeConfigPath, err := simKubeConfigPath()
 	if err != nil {
 		return err
 	}
+
+	contents, err := s.Runtime.ReadFile(s.Name, defaultKubeConfigPath)
+	if err != nil {
+		return fmt.Errorf("error fetching kubeconfig from container %s: %w", s.Name, err)
+	}
+
+	// recorded before adding as a failed write may leave a partial context behind
+	s.tx.record(fmt.Sprintf("kubeconfig context %s", s.Name), func(runtime.Runtime) error { return kubeconfig.RemoveContext(kubeConfigPath, s.Name) })
 	err = kubeconfig.AddContext(kubeConfigPath, s.Name, endpoint, port, contents)
 	if err != nil {
 		return fmt.Errorf("error adding context for %s to kubeconfig: %w", s.Name, err)
@@ -102,20 +284,164 @@ func (s *Simulator) ExportKubeConfig() error {
 
 func (s *Simulator) RemoveInstance() error {
 	logrus.Infof("removing instance %s", s.Name)
-	if err := s.DockerClient.StopContainer(s.Name); err != nil {
+	containers, err := s.Runtime.FindContainer(s.Name)
+	if err != nil {
+		return fmt.Errorf("error listing containers: %w", err)
+	}
+
+	if err := s.Runtime.RemoveContainer(s.Name); err != nil {
 		return fmt.Errorf("error removing container %s: %w", s.Name, err)
 	}
 
+	// instances running in mount mode have the bundle in a volume
+	for _, v := range containers {
+		volume, ok := v.Labels[runtime.BundleVolumeLabel]
+		if !ok {
+			continue
+		}
+		logrus.Infof("removing bundle volume for instance %s", s.Name)
+		if err := s.Runtime.RemoveVolume(volume); err != nil {
+			return fmt.Errorf("error removing volume for instance %s: %w", s.Name, err)
+		}
+	}
+
 	logrus.Infof("removing image for instance %s", s.Name)
-	if err := s.DockerClient.RemoveImages(s.Name); err != nil {
+	if err := s.Runtime.RemoveImages(s.Name); err != nil {
 		return fmt.Errorf("error removing image for instance %s: %w", s.Name, err)
 	}
 
-	home, err := os.UserHomeDir()
+	kubeConfigPath, err := simKubeConfigPath()
 	if err != nil {
-		return fmt.Errorf("error fetching home directory: %w", err)
+		return err
 	}
 	logrus.Infof("removing context for instance %s", s.Name)
-	kubeConfigPath := filepath.Join(home, defaultSimKubeConfigPath)
 	return kubeconfig.RemoveContext(kubeConfigPath, s.Name)
 }
+
+// StopInstance stops the simulator container, retaining the container and simulator state so the
+// instance can be started again
+func (s *Simulator) StopInstance() error {
+	if _, err := s.findInstance(); err != nil {
+		return err
+	}
+
+	logrus.Infof("stopping instance %s", s.Name)
+	if err := s.Runtime.StopContainer(s.Name); err != nil {
+		return fmt.Errorf("error stopping container %s: %w", s.Name, err)
+	}
+	logrus.Infof("stopped instance %s", s.Name)
+	return nil
+}
+
+// StartInstance starts a stopped simulator container and waits for it to become ready. The runtime may publish
+// the api server on a different host port, in which case the kubeconfig is exported again
+func (s *Simulator) StartInstance(timeout time.Duration) error {
+	if _, err := s.findInstance(); err != nil {
+		return err
+	}
+
+	logrus.Infof("starting instance %s", s.Name)
+	if err := s.Runtime.StartContainer(s.Name); err != nil {
+		return fmt.Errorf("error starting container %s: %w", s.Name, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if err := s.waitForKubeConfig(deadline); err != nil {
+		return err
+	}
+
+	changed, err := s.endpointChanged()
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		if err := s.ExportKubeConfig(); err != nil {
+			return err
+		}
+	} else {
+		logrus.Infof("kubeconfig context %s is up to date", s.Name)
+	}
+
+	return s.waitForAPIServer(deadline)
+}
+
+// RestartInstance stops and starts the simulator container
+func (s *Simulator) RestartInstance(timeout time.Duration) error {
+	if err := s.StopInstance(); err != nil {
+		return err
+	}
+	return s.StartInstance(timeout)
+}
+
+// Logs writes the logs of the simulator container to stdout and stderr, and works for running and stopped instances
+func (s *Simulator) Logs(opts runtime.LogOptions, stdout, stderr io.Writer) error {
+	if _, err := s.findInstance(); err != nil {
+		return err
+	}
+	return s.Runtime.StreamLogs(s.Name, opts, stdout, stderr)
+}
+
+// Exec runs cmd in the running simulator container, and returns an ExitCodeError if cmd exits with a non zero code
+func (s *Simulator) Exec(opts runtime.ExecOptions) error {
+	if _, err := s.findInstance(); err != nil {
+		return err
+	}
+
+	code, err := s.Runtime.Exec(s.Name, opts)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return &ExitCodeError{Code: code}
+	}
+	return nil
+}
+
+// findInstance returns the container for the instance, including stopped containers
+func (s *Simulator) findInstance() (types.Container, error) {
+	containers, err := s.Runtime.FindContainer(s.Name)
+	if err != nil {
+		return types.Container{}, fmt.Errorf("error listing containers: %w", err)
+	}
+
+	if len(containers) != 1 {
+		return types.Container{}, fmt.Errorf("expected to find 1 container for instance %s but found %d", s.Name, len(containers))
+	}
+	return containers[0], nil
+}
+
+// endpointChanged checks if the address the api server is exposed on differs from the server in the kubeconfig context
+func (s *Simulator) endpointChanged() (bool, error) {
+	kubeConfigPath, err := simKubeConfigPath()
+	if err != nil {
+		return false, err
+	}
+
+	server, err := kubeconfig.ServerURL(kubeConfigPath, s.Name)
+	if err != nil {
+		return false, err
+	}
+
+	endpoint, port, err := s.Runtime.QueryExposedMapping(s.Name)
+	if err != nil {
+		return false, err
+	}
+
+	current := kubeconfig.ServerAddress(endpoint, port)
+	if server != current {
+		logrus.Infof("api server for instance %s moved from %s to %s", s.Name, server, current)
+		return true, nil
+	}
+	return false, nil
+}
+
+// simKubeConfigPath returns the location of the kubeconfig containing contexts for simulator instances
+func simKubeConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error fetching home directory: %w", err)
+	}
+	return filepath.Join(home, defaultSimKubeConfigPath), nil
+}
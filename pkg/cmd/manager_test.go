@@ -0,0 +1,619 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime/fake"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	testInstance = "issue-7007"
+)
+
+var errInjected = errors.New("injected error")
+
+// testBundleDigest is the sha256 digest of testBundle
+const testBundleDigest = "8739c76e681f900923b900c9df0ef75cf421d39cabb54650c4b9ad19b6a76d85"
+
+// testBundle is the end of central directory record of an empty zip file, the smallest valid bundle
+var testBundle = []byte("PK\x05\x06\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+
+// newTestSimulator returns a simulator backed by a fake runtime, with HOME pointing at a temp directory
+// to ensure tests do not modify the users kubeconfig
+func newTestSimulator(t *testing.T) (*Simulator, *fake.Runtime) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	kubeconfig, err := os.ReadFile("testdata/admin.kubeconfig")
+	require.NoError(t, err)
+
+	bundlePath := filepath.Join(t.TempDir(), "supportbundle.zip")
+	require.NoError(t, os.WriteFile(bundlePath, testBundle, 0600))
+
+	r := fake.NewRuntime()
+	r.Files[defaultKubeConfigPath] = kubeconfig
+	r.Logs = "simulator failed to start"
+	return &Simulator{
+		Name:       testInstance,
+		BundlePath: bundlePath,
+		Image:      Image,
+		Mode:       ModeImage,
+		PullPolicy: PullMissing,
+		Ctx:        context.TODO(),
+		Runtime:    r,
+	}, r
+}
+
+// loadSimKubeConfig returns contexts defined in the sim kubeconfig in the test HOME directory
+func loadSimKubeConfig(t *testing.T) map[string]string {
+	t.Helper()
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	config, err := clientcmd.LoadFromFile(filepath.Join(home, defaultSimKubeConfigPath))
+	require.NoError(t, err)
+	servers := make(map[string]string)
+	for name, v := range config.Contexts {
+		servers[name] = config.Clusters[v.Cluster].Server
+	}
+	return servers
+}
+
+func Test_PreFlightChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(s *Simulator, r *fake.Runtime)
+		expectError bool
+	}{
+		{
+			name:  "valid bundle and no existing instance",
+			setup: func(s *Simulator, r *fake.Runtime) {},
+		},
+		{
+			name: "missing bundle",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				s.BundlePath = filepath.Join(filepath.Dir(s.BundlePath), "missing.zip")
+			},
+			expectError: true,
+		},
+		{
+			name: "bundle is a directory",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				s.BundlePath = filepath.Dir(s.BundlePath)
+			},
+		},
+		{
+			name: "unsupported bundle format",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				require.NoError(t, os.WriteFile(s.BundlePath, []byte("bundle"), 0600))
+			},
+			expectError: true,
+		},
+		{
+			name: "instance already running",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				require.NoError(t, r.CreateImage(s.Name, s.BundlePath, testBundleDigest, s.Image, nil))
+				require.NoError(t, r.RunContainer(runtime.RunOptions{InstanceName: s.Name, BundlePath: s.BundlePath}))
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported mode",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				s.Mode = "copy"
+			},
+			expectError: true,
+		},
+		{
+			name: "unsupported pull policy",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				s.PullPolicy = "sometimes"
+			},
+			expectError: true,
+		},
+		{
+			name: "error listing containers",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				r.Errors["FindContainer"] = errInjected
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, r := newTestSimulator(t)
+			tt.setup(s, r)
+			err := s.PreFlightChecks()
+			if tt.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_CreateNewInstance(t *testing.T) {
+	tests := []struct {
+		name           string
+		failOn         string
+		expectImage    bool
+		expectRunning  bool
+		expectPort     int
+		expectedErrMsg string
+	}{
+		{
+			name:          "instance created",
+			expectImage:   true,
+			expectRunning: true,
+			expectPort:    32768,
+		},
+		{
+			name:           "image creation fails",
+			failOn:         "CreateImage",
+			expectedErrMsg: "error creating new sim image",
+		},
+		{
+			name:           "container fails to run",
+			failOn:         "RunContainer",
+			expectImage:    true,
+			expectedErrMsg: "error running new image",
+		},
+		{
+			name:           "container lookup fails",
+			failOn:         "FindRunningContainer",
+			expectImage:    true,
+			expectRunning:  true,
+			expectedErrMsg: "error listing running containers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			if tt.failOn != "" {
+				r.Errors[tt.failOn] = errInjected
+			}
+			err := s.CreateNewInstance()
+			if tt.expectedErrMsg != "" {
+				assert.ErrorIs(err, errInjected)
+				assert.ErrorContains(err, tt.expectedErrMsg)
+			} else {
+				assert.NoError(err)
+			}
+
+			image, ok := r.Images[s.Name]
+			assert.Equal(tt.expectImage, ok)
+			if ok {
+				assert.Equal(s.Image, image.BaseImage)
+				assert.Equal(s.BundlePath, image.BundlePath)
+			}
+
+			c, ok := r.Containers[s.Name]
+			assert.Equal(tt.expectRunning, ok)
+			if ok {
+				assert.Equal(s.Name, c.Labels["sim-cli-managed"])
+				assert.Equal(s.BundlePath, c.Labels["harvesterhci.io/bundle-name"])
+			}
+			assert.Equal(tt.expectPort, s.Port)
+		})
+	}
+}
+
+func Test_CreateNewInstanceNotRunning(t *testing.T) {
+	tests := []struct {
+		name          string
+		onRun         func(c *fake.Container)
+		expectError   error
+		expectMessage string
+	}{
+		{
+			name: "container exits on start",
+			onRun: func(c *fake.Container) {
+				c.Running = false
+				c.ExitCode = 1
+			},
+			expectMessage: "exited unexpectedly with exit code 1",
+		},
+		{
+			name: "container created but never started",
+			onRun: func(c *fake.Container) {
+				c.Running = false
+				c.State = "created"
+			},
+			expectMessage: "was created but never started",
+		},
+		{
+			name:        "container running without published port",
+			onRun:       func(c *fake.Container) { c.Unpublished = true },
+			expectError: runtime.ErrNoPublishedPort,
+		},
+		{
+			name: "container restarting",
+			onRun: func(c *fake.Container) {
+				c.State = "restarting"
+				c.Unpublished = true
+			},
+			expectError: runtime.ErrNoPublishedPort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			r.OnRun = tt.onRun
+			err := s.CreateNewInstance()
+			if tt.expectError != nil {
+				assert.ErrorIs(err, tt.expectError)
+				assert.ErrorIs(s.ExportKubeConfig(), tt.expectError)
+			} else {
+				assert.True(isExited(err), "expected exited error, got %v", err)
+				assert.ErrorContains(err, tt.expectMessage)
+				assert.Error(s.ExportKubeConfig())
+			}
+			assert.Equal(0, s.Port)
+		})
+	}
+}
+
+func Test_ExportKubeConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		failOn      string
+		noContainer bool
+		stopped     bool
+		expectError bool
+	}{
+		{
+			name: "kubeconfig exported",
+		},
+		{
+			name:        "instance not found",
+			noContainer: true,
+			expectError: true,
+		},
+		{
+			name:        "instance stopped",
+			stopped:     true,
+			expectError: true,
+		},
+		{
+			name:        "reading kubeconfig fails",
+			failOn:      "ReadFile",
+			expectError: true,
+		},
+		{
+			name:        "querying exposed port fails",
+			failOn:      "QueryExposedMapping",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			if !tt.noContainer {
+				assert.NoError(s.CreateNewInstance())
+			}
+			if tt.stopped {
+				assert.NoError(s.StopInstance())
+			}
+			if tt.failOn != "" {
+				r.Errors[tt.failOn] = errInjected
+			}
+
+			err := s.ExportKubeConfig()
+			if tt.stopped {
+				assert.ErrorContains(err, fmt.Sprintf("instance %s is not running (state: exited)", s.Name))
+				assert.ErrorIs(err, runtime.ErrNoPublishedPort)
+				return
+			}
+			if tt.expectError {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			servers := loadSimKubeConfig(t)
+			assert.Equal("https://localhost:32768", servers[s.Name])
+		})
+	}
+}
+
+func Test_RemoveInstance(t *testing.T) {
+	tests := []struct {
+		name          string
+		failOn        string
+		expectImage   bool
+		expectContext bool
+		expectError   bool
+	}{
+		{
+			name: "instance removed",
+		},
+		{
+			name:          "removing container fails",
+			failOn:        "RemoveContainer",
+			expectImage:   true,
+			expectContext: true,
+			expectError:   true,
+		},
+		{
+			name:          "removing image fails",
+			failOn:        "RemoveImages",
+			expectImage:   true,
+			expectContext: true,
+			expectError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			assert.NoError(s.CreateNewInstance())
+			assert.NoError(s.ExportKubeConfig())
+			if tt.failOn != "" {
+				r.Errors[tt.failOn] = errInjected
+			}
+
+			err := s.RemoveInstance()
+			if tt.expectError {
+				assert.ErrorIs(err, errInjected)
+			} else {
+				assert.NoError(err)
+			}
+
+			_, ok := r.Images[s.Name]
+			assert.Equal(tt.expectImage, ok)
+			_, ok = loadSimKubeConfig(t)[s.Name]
+			assert.Equal(tt.expectContext, ok)
+		})
+	}
+}
+
+func Test_CreateNewInstanceWithPort(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.PortRange = "30000-30010"
+	s.BindAddress = "127.0.0.1"
+	assert.NoError(s.CreateNewInstance())
+	assert.Equal(30000, s.Port)
+	c := r.Containers[s.Name]
+	assert.Equal("127.0.0.1", c.Labels[runtime.BindAddressLabel])
+	assert.Equal("30000", c.Labels[runtime.HostPortLabel])
+
+	// port conflicts are detected before any image is built
+	second, _ := newTestSimulator(t)
+	second.Runtime = r
+	second.Name = "issue-7007-retry"
+	second.HostPort = 30000
+	assert.ErrorContains(second.CreateNewInstance(), "already used by instance issue-7007")
+	_, ok := r.Images[second.Name]
+	assert.False(ok, "expected no image to be built for conflicting port")
+}
+
+func Test_MountMode(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.Mode = ModeMount
+	assert.NoError(s.PreFlightChecks())
+	assert.NoError(s.CreateNewInstance())
+	assert.Empty(r.Images, "expected no image to be built in mount mode")
+	assert.Len(r.Volumes, 1)
+	c := r.Containers[s.Name]
+	assert.Equal(s.Image, c.Image, "expected container to run base image")
+	assert.NotEmpty(c.Volume)
+	assert.Equal(c.Volume, c.Labels[runtime.BundleVolumeLabel])
+	assert.Equal(testBundleDigest, r.Volumes[c.Volume].BundleDigest, "expected digest computed for metadata to identify the volume")
+
+	// second instance for same bundle shares the volume
+	second := *s
+	second.Name = "issue-7007-retry"
+	assert.NoError(second.CreateNewInstance())
+	assert.Len(r.Volumes, 1, "expected volume to be reused")
+
+	assert.NoError(s.RemoveInstance())
+	assert.Len(r.Volumes, 1, "expected volume in use by second instance to be retained")
+	assert.NoError(second.RemoveInstance())
+	assert.Empty(r.Volumes, "expected volume to be removed with last instance")
+
+	// failure to create volume
+	r.Errors["CreateBundleVolume"] = errInjected
+	assert.ErrorIs(s.CreateNewInstance(), errInjected)
+	assert.Empty(r.Containers)
+}
+
+func Test_WaitForReady(t *testing.T) {
+	assert := require.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+	port, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(err)
+
+	s, r := newTestSimulator(t)
+	r.Endpoint = serverURL.Hostname()
+	assert.NoError(s.CreateNewInstance())
+	r.Containers[s.Name].Port = uint16(port)
+	assert.NoError(s.WaitForReady(defaultPollInterval))
+
+	// instance which exits during startup
+	s, r = newTestSimulator(t)
+	assert.NoError(s.CreateNewInstance())
+	c := r.Containers[s.Name]
+	c.Running = false
+	c.ExitCode = 2
+	waitErr := s.WaitForReady(time.Minute)
+	assert.ErrorContains(waitErr, "exited unexpectedly with exit code 2")
+	home, err := os.UserHomeDir()
+	assert.NoError(err)
+	crashDirs, err := filepath.Glob(filepath.Join(home, defaultInstancesDir, s.Name, crashDirPrefix+"*"))
+	assert.NoError(err)
+	assert.Len(crashDirs, 1, "expected crash diagnostics to be captured")
+	assert.ErrorContains(waitErr, crashDirs[0], "expected error to point to crash diagnostics")
+	for _, v := range []string{"inspect.json", "exit-code", "container.log"} {
+		assert.FileExists(filepath.Join(crashDirs[0], v))
+	}
+	logs, err := os.ReadFile(filepath.Join(crashDirs[0], "container.log"))
+	assert.NoError(err)
+	assert.Equal(r.Logs, string(logs))
+
+	// instance which is killed after running out of memory during startup
+	s, r = newTestSimulator(t)
+	s.Memory = "512m"
+	assert.NoError(s.CreateNewInstance())
+	c = r.Containers[s.Name]
+	c.Running = false
+	c.ExitCode = 137
+	c.OOMKilled = true
+	err = s.WaitForReady(time.Minute)
+	assert.ErrorContains(err, "running out of memory")
+	assert.ErrorContains(err, "memory limit of 512MiB")
+
+	// instance which is removed during startup
+	s, r = newTestSimulator(t)
+	assert.NoError(s.CreateNewInstance())
+	delete(r.Containers, s.Name)
+	start := time.Now()
+	err = s.WaitForReady(time.Minute)
+	assert.ErrorContains(err, fmt.Sprintf("instance %s is not ready", s.Name))
+	assert.ErrorContains(err, "was removed while waiting")
+	assert.Less(time.Since(start), defaultPollInterval, "expected wait to stop once the container was removed")
+
+	// instance where kubeconfig is never generated
+	s, r = newTestSimulator(t)
+	delete(r.Files, defaultKubeConfigPath)
+	assert.NoError(s.CreateNewInstance())
+	err = s.WaitForReady(0)
+	assert.ErrorContains(err, "timed out waiting for kubeconfig")
+	assert.ErrorContains(err, r.Logs, "expected container logs to be reported")
+}
+
+func Test_StopStartInstance(t *testing.T) {
+	assert := require.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+
+	s, r := newTestSimulator(t)
+	r.Endpoint = serverURL.Hostname()
+	assert.ErrorContains(s.StopInstance(), "found 0", "expected error stopping missing instance")
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.ExportKubeConfig())
+
+	assert.NoError(s.StopInstance())
+	c := r.Containers[s.Name]
+	assert.False(c.Running, "expected container to be stopped")
+	assert.ErrorContains(s.PreFlightChecks(), "already exist", "expected stopped instance to block reuse of name")
+
+	// fake runtime allocates a new port on start, which is served by the test server
+	port, err := strconv.Atoi(serverURL.Port())
+	assert.NoError(err)
+	r.NextPort = uint16(port)
+	r.Errors["StartContainer"] = errInjected
+	assert.ErrorIs(s.StartInstance(defaultPollInterval), errInjected)
+	delete(r.Errors, "StartContainer")
+	assert.NoError(s.StartInstance(defaultPollInterval))
+	assert.True(c.Running, "expected container to be running")
+	assert.Equal(server.URL, loadSimKubeConfig(t)[s.Name], "expected context to be updated with new port")
+
+	// port is retained when a host port was requested
+	c.Labels[runtime.HostPortLabel] = serverURL.Port()
+	assert.NoError(s.RestartInstance(defaultPollInterval))
+	assert.Equal(server.URL, loadSimKubeConfig(t)[s.Name])
+	assert.NoError(s.RemoveInstance())
+	assert.Empty(r.Containers)
+}
+
+func Test_Logs(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	stdout := new(bytes.Buffer)
+	assert.Error(s.Logs(runtime.LogOptions{}, stdout, stdout), "expected error for missing instance")
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.StopInstance())
+	assert.NoError(s.Logs(runtime.LogOptions{Tail: "all"}, stdout, stdout), "expected logs for stopped instance")
+	assert.Equal(r.Logs, stdout.String())
+}
+
+func Test_Exec(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	stdout := new(bytes.Buffer)
+	opts := runtime.ExecOptions{Cmd: []string{"ls", "/bundle"}, Stdout: stdout, Stderr: stdout}
+	assert.Error(s.Exec(opts), "expected error for missing instance")
+	assert.NoError(s.CreateNewInstance())
+
+	r.ExecOutput = "nodes\n"
+	assert.NoError(s.Exec(opts))
+	assert.Equal(r.ExecOutput, stdout.String())
+	assert.Equal([][]string{{"ls", "/bundle"}}, r.Execs[s.Name])
+
+	r.ExecExitCode = 2
+	err := s.Exec(opts)
+	var exitErr *ExitCodeError
+	assert.ErrorAs(err, &exitErr)
+	assert.Equal(2, exitErr.Code)
+
+	assert.NoError(s.StopInstance())
+	assert.Error(s.Exec(opts), "expected error for stopped instance")
+}
+
+func Test_CreateNewInstanceWithLimits(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.CPUs = "1.5"
+	s.Memory = "4g"
+	assert.NoError(s.PreFlightChecks())
+	assert.NoError(s.CreateNewInstance())
+	labels := r.Containers[s.Name].Labels
+	assert.Equal("1500000000", labels[runtime.CPUsLabel])
+	assert.Equal("4294967296", labels[runtime.MemoryLabel])
+
+	s, _ = newTestSimulator(t)
+	s.Memory = "lots"
+	assert.ErrorContains(s.PreFlightChecks(), "invalid memory")
+}
+
+func Test_CreateNewInstanceMetadata(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	Version = "v0.1.0"
+	t.Cleanup(func() { Version = "dev" })
+	assert.NoError(s.CreateNewInstance())
+
+	metadata := runtime.ParseMetadata(r.Containers[s.Name].Labels)
+	assert.Equal(runtime.MetadataSchemaVersion, metadata.SchemaVersion)
+	assert.Equal(s.BundlePath, metadata.BundlePath)
+	assert.Equal(testBundleDigest, metadata.BundleSHA256)
+	assert.Equal(int64(len(testBundle)), metadata.BundleSize)
+	assert.WithinDuration(time.Now(), metadata.Created, time.Minute)
+	assert.Equal(s.Image, metadata.BaseImage)
+	assert.Contains(metadata.BaseImageDigest, s.Image+"@sha256:")
+	assert.Equal("v0.1.0", metadata.Version)
+	assert.NotEmpty(metadata.CreatedBy)
+	assert.Equal(metadata.BundleSHA256, r.Images[s.Name].Labels[runtime.BundleSHA256Label], "expected image to be labelled with metadata")
+	assert.Equal(metadata.BundleSHA256, r.Images[s.Name].BundleDigest, "expected digest computed for metadata to identify the image")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/docker"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// instanceMetadata generates the metadata recorded on a new instance
+func (s *Simulator) instanceMetadata() (runtime.Metadata, error) {
+	bundleSize, err := docker.BundleSize(s.BundlePath)
+	if err != nil {
+		return runtime.Metadata{}, err
+	}
+
+	digest, err := docker.BundleDigest(s.Ctx, s.BundlePath)
+	if err != nil {
+		return runtime.Metadata{}, err
+	}
+
+	m := runtime.Metadata{
+		SchemaVersion: runtime.MetadataSchemaVersion,
+		BundlePath:    s.BundlePath,
+		BundleSHA256:  digest,
+		BundleSize:    bundleSize,
+		Created:       time.Now().UTC(),
+		BaseImage:     s.Image,
+		Version:       Version,
+		CreatedBy:     currentUser(),
+	}
+
+	m.BaseImageDigest, err = s.Runtime.ImageDigest(s.Image)
+	if err != nil {
+		logrus.WithError(err).Warnf("unable to identify digest of base image %s", s.Image)
+	}
+	return m, nil
+}
+
+// currentUser returns the name of the user running sim-cli
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
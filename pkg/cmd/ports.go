@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// selectPort identifies the host port for a new instance. A requested port is checked for conflicts
+// with existing sim-cli managed instances, otherwise the first port in the port range not used by an
+// existing instance is selected. When neither is specified 0 is returned, and the runtime picks a random port
+func (s *Simulator) selectPort() (int, error) {
+	if s.HostPort == 0 && s.PortRange == "" {
+		return 0, nil
+	}
+
+	containers, err := s.Runtime.FindAllSimManagedContainers()
+	if err != nil {
+		return 0, fmt.Errorf("error listing sim-cli managed containers: %w", err)
+	}
+	used := usedPorts(containers)
+
+	if s.HostPort != 0 {
+		if instance, ok := used[s.HostPort]; ok {
+			return 0, fmt.Errorf("port %d is already used by instance %s", s.HostPort, instance)
+		}
+		return s.HostPort, nil
+	}
+
+	start, end, err := parsePortRange(s.PortRange)
+	if err != nil {
+		return 0, err
+	}
+
+	for port := start; port <= end; port++ {
+		if _, ok := used[port]; !ok {
+			logrus.Debugf("selected port %d from range %s", port, s.PortRange)
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free ports available in range %s", s.PortRange)
+}
+
+// usedPorts returns the host ports used by sim-cli managed containers mapped to the instance name
+func usedPorts(containers []types.Container) map[int]string {
+	used := make(map[int]string)
+	for _, v := range containers {
+		name := v.Labels[runtime.InstanceLabel]
+		if port, err := strconv.Atoi(v.Labels[runtime.HostPortLabel]); err == nil {
+			used[port] = name
+		}
+
+		for _, p := range v.Ports {
+			if p.PublicPort != 0 {
+				used[int(p.PublicPort)] = name
+			}
+		}
+	}
+	return used
+}
+
+// parsePortRange parses a port range in the form start-end
+func parsePortRange(portRange string) (int, int, error) {
+	startPort, endPort, ok := strings.Cut(portRange, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid port range %s, expected format start-end", portRange)
+	}
+
+	start, err := parsePort(startPort)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %s: %w", portRange, err)
+	}
+
+	end, err := parsePort(endPort)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %s: %w", portRange, err)
+	}
+
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid port range %s, start port is greater than end port", portRange)
+	}
+	return start, end, nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %s: %w", value, err)
+	}
+
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d is out of range", port)
+	}
+	return port, nil
+}
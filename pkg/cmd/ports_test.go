@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parsePortRange(t *testing.T) {
+	tests := []struct {
+		portRange   string
+		start       int
+		end         int
+		expectError bool
+	}{
+		{portRange: "30000-30010", start: 30000, end: 30010},
+		{portRange: "30000-30000", start: 30000, end: 30000},
+		{portRange: "30000", expectError: true},
+		{portRange: "30010-30000", expectError: true},
+		{portRange: "0-10", expectError: true},
+		{portRange: "30000-70000", expectError: true},
+		{portRange: "a-b", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.portRange, func(t *testing.T) {
+			start, end, err := parsePortRange(tt.portRange)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.start, start)
+			require.Equal(t, tt.end, end)
+		})
+	}
+}
+
+func Test_selectPort(t *testing.T) {
+	tests := []struct {
+		name         string
+		port         int
+		portRange    string
+		expectedPort int
+		expectError  bool
+	}{
+		{
+			name:         "random port",
+			expectedPort: 0,
+		},
+		{
+			name:         "requested port is free",
+			port:         30005,
+			expectedPort: 30005,
+		},
+		{
+			name:        "requested port is used by another instance",
+			port:        30000,
+			expectError: true,
+		},
+		{
+			name:         "first free port in range",
+			portRange:    "30000-30010",
+			expectedPort: 30002,
+		},
+		{
+			name:        "no free port in range",
+			portRange:   "30000-30001",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			// existing instances using ports 30000 and 30001
+			for i, name := range []string{"issue-113", "issue-114"} {
+				assert.NoError(r.CreateImage(name, s.BundlePath, testBundleDigest, s.Image, nil))
+				assert.NoError(r.RunContainer(runtime.RunOptions{InstanceName: name, BundlePath: s.BundlePath, HostPort: 30000 + i}))
+			}
+
+			s.HostPort = tt.port
+			s.PortRange = tt.portRange
+			port, err := s.selectPort()
+			if tt.expectError {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.expectedPort, port)
+		})
+	}
+}
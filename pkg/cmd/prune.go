@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/ibrokethecloud/sim-cli/pkg/kubeconfig"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+)
+
+// Prune removes resources left behind by failed or interrupted instances: containers which were never started
+// or are dead, images tagged for instances without a container, bundle volumes not used by any instance and
+// kubeconfig contexts for instances without a container. A summary of removed resources and reclaimed space
+// is written to out, and when dryRun is set resources are only reported
+func (s *Simulator) Prune(dryRun bool, out io.Writer) error {
+	containers, err := s.Runtime.FindAllSimManagedContainers()
+	if err != nil {
+		return fmt.Errorf("error listing sim-cli managed containers: %w", err)
+	}
+
+	images, err := s.Runtime.FindAllSimManagedImages()
+	if err != nil {
+		return fmt.Errorf("error listing sim-cli managed images: %w", err)
+	}
+
+	volumes, err := s.Runtime.FindAllBundleVolumes()
+	if err != nil {
+		return fmt.Errorf("error listing bundle volumes: %w", err)
+	}
+
+	kubeConfigPath, err := simKubeConfigPath()
+	if err != nil {
+		return err
+	}
+
+	contexts, err := kubeconfig.Contexts(kubeConfigPath)
+	if err != nil {
+		return err
+	}
+
+	action := "removed"
+	if dryRun {
+		action = "would remove"
+	}
+
+	// instances with a container which is not being pruned keep their image, volume and context
+	instances := make(map[string]bool)
+	usedVolumes := make(map[string]bool)
+	var errs []error
+	for _, v := range containers {
+		name := v.Labels[runtime.InstanceLabel]
+		state := runtime.ContainerState(v)
+		if state != runtime.StateCreated && state != runtime.StateDead {
+			instances[name] = true
+			if volume, ok := v.Labels[runtime.BundleVolumeLabel]; ok {
+				usedVolumes[volume] = true
+			}
+			continue
+		}
+
+		if !dryRun {
+			if err := s.Runtime.RemoveContainer(name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		fmt.Fprintf(out, "%s container for instance %s in state %s\n", action, name, state)
+	}
+
+	var reclaimed int64
+	for _, v := range images {
+		var orphaned []string
+		for _, instance := range v.Instances {
+			if !instances[instance] {
+				orphaned = append(orphaned, instance)
+			}
+		}
+
+		if len(orphaned) == 0 {
+			continue
+		}
+
+		var failed bool
+		for _, instance := range orphaned {
+			if dryRun {
+				continue
+			}
+			if err := s.Runtime.RemoveImages(instance); err != nil {
+				errs = append(errs, err)
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+
+		// image is only deleted once it is not tagged for any instance
+		if len(orphaned) == len(v.Instances) {
+			reclaimed += v.Size
+			fmt.Fprintf(out, "%s image %s for instances %s\n", action, shortID(v.ID), strings.Join(orphaned, ", "))
+		} else {
+			fmt.Fprintf(out, "%s tags for instances %s from image %s\n", action, strings.Join(orphaned, ", "), shortID(v.ID))
+		}
+	}
+
+	for _, v := range volumes {
+		if usedVolumes[v.Name] {
+			continue
+		}
+
+		if !dryRun {
+			if err := s.Runtime.RemoveVolume(v.Name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if v.Size > 0 {
+			reclaimed += v.Size
+		}
+		fmt.Fprintf(out, "%s volume %s for bundle %s\n", action, v.Name, v.BundlePath)
+	}
+
+	for _, v := range contexts {
+		if instances[v] {
+			continue
+		}
+
+		if !dryRun {
+			if err := kubeconfig.RemoveContext(kubeConfigPath, v); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		fmt.Fprintf(out, "%s context %s\n", action, v)
+	}
+
+	if dryRun {
+		fmt.Fprintf(out, "total reclaimable space: %s\n", units.HumanSize(float64(reclaimed)))
+	} else {
+		fmt.Fprintf(out, "total reclaimed space: %s\n", units.HumanSize(float64(reclaimed)))
+	}
+	return errors.Join(errs...)
+}
+
+// shortID returns the truncated ID of an image, in the same format as the docker cli
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/kubeconfig"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime/fake"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Prune(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+
+	// running instance in mount mode which is retained
+	s.Mode = ModeMount
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.ExportKubeConfig())
+	retainedVolume := r.Containers[s.Name].Volume
+
+	// stopped instance which is retained
+	s.Name = "issue-stopped"
+	s.Mode = ModeImage
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.ExportKubeConfig())
+	assert.NoError(s.StopInstance())
+
+	// instance which was never started
+	s.Name = "issue-created"
+	assert.NoError(s.CreateNewInstance())
+	assert.NoError(s.ExportKubeConfig())
+	r.Containers[s.Name].State = "created"
+	r.Images[s.Name].Size = 1000000
+
+	// image and volume from a failed create, and context for a deleted instance
+	r.Images["issue-failed"] = &fake.Image{ID: "sha256:1234567890abcdef", Name: "sim-cli-managed:issue-failed", Size: 2000000}
+	r.Volumes["sim-cli-bundle-orphaned"] = &fake.Volume{Name: "sim-cli-bundle-orphaned", BundlePath: "/tmp/bundle.zip", Size: 500000}
+	kubeConfigPath, err := simKubeConfigPath()
+	assert.NoError(err)
+	contents, err := os.ReadFile(filepath.Join("testdata", "admin.kubeconfig"))
+	assert.NoError(err)
+	assert.NoError(kubeconfig.AddContext(kubeConfigPath, "issue-deleted", "localhost", "30000", contents))
+
+	out := new(bytes.Buffer)
+	assert.NoError(s.Prune(true, out))
+	assert.Contains(out.String(), "would remove container for instance issue-created in state created")
+	assert.Contains(out.String(), "would remove image 1234567890ab for instances issue-failed")
+	assert.Contains(out.String(), "would remove volume sim-cli-bundle-orphaned for bundle /tmp/bundle.zip")
+	assert.Contains(out.String(), "would remove context issue-deleted")
+	assert.Contains(out.String(), "would remove context issue-created")
+	assert.Contains(out.String(), "total reclaimable space: 3.5MB")
+	assert.Len(r.Containers, 3, "expected no containers to be removed in dry run")
+	assert.Len(r.Images, 3, "expected no images to be removed in dry run")
+	assert.Len(r.Volumes, 2, "expected no volumes to be removed in dry run")
+	assert.Len(loadSimKubeConfig(t), 4, "expected no contexts to be removed in dry run")
+
+	out.Reset()
+	assert.NoError(s.Prune(false, out))
+	assert.Contains(out.String(), "total reclaimed space: 3.5MB")
+	assert.NotContains(r.Containers, "issue-created")
+	assert.Contains(r.Containers, "issue-stopped")
+	assert.Contains(r.Containers, testInstance)
+	assert.NotContains(r.Images, "issue-created")
+	assert.NotContains(r.Images, "issue-failed")
+	assert.Contains(r.Images, "issue-stopped")
+	assert.Contains(r.Volumes, retainedVolume)
+	assert.NotContains(r.Volumes, "sim-cli-bundle-orphaned")
+	contexts := loadSimKubeConfig(t)
+	assert.Len(contexts, 2)
+	assert.Contains(contexts, testInstance)
+	assert.Contains(contexts, "issue-stopped")
+
+	out.Reset()
+	assert.NoError(s.Prune(false, out))
+	assert.Equal("total reclaimed space: 0B\n", out.String(), "expected nothing left to prune")
+}
+
+func Test_PruneContinuesOnError(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	r.Images["issue-failed"] = &fake.Image{ID: "sha256:1234567890abcdef", Name: "sim-cli-managed:issue-failed"}
+	r.Volumes["sim-cli-bundle-orphaned"] = &fake.Volume{Name: "sim-cli-bundle-orphaned"}
+	r.Errors["RemoveImages"] = errInjected
+
+	out := new(bytes.Buffer)
+	assert.ErrorIs(s.Prune(false, out), errInjected)
+	assert.Contains(r.Images, "issue-failed")
+	assert.NotContains(r.Volumes, "sim-cli-bundle-orphaned", "expected volume to be removed after image removal failed")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/go-units"
+)
+
+// resourceLimits parses the cpu and memory limits for a new instance, and returns them in nano cpus and bytes.
+// Empty limits are returned as 0, which leaves the simulator container unlimited
+func (s *Simulator) resourceLimits() (int64, int64, error) {
+	nanoCPUs, err := parseCPUs(s.CPUs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	memory, err := parseMemory(s.Memory)
+	if err != nil {
+		return 0, 0, err
+	}
+	return nanoCPUs, memory, nil
+}
+
+// parseCPUs converts a number of cpus, like 1.5, to nano cpus
+func parseCPUs(cpus string) (int64, error) {
+	if cpus == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseFloat(cpus, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid cpus %s, expected a positive number of cpus like 1.5", cpus)
+	}
+	return int64(value * 1e9), nil
+}
+
+// parseMemory converts a memory size, like 4g or 512m, to bytes
+func parseMemory(memory string) (int64, error) {
+	if memory == "" {
+		return 0, nil
+	}
+
+	value, err := units.RAMInBytes(memory)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid memory %s, expected a size like 4g or 512m", memory)
+	}
+	return value, nil
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseCPUs(t *testing.T) {
+	tests := []struct {
+		cpus        string
+		nanoCPUs    int64
+		expectError bool
+	}{
+		{cpus: "", nanoCPUs: 0},
+		{cpus: "2", nanoCPUs: 2e9},
+		{cpus: "0.5", nanoCPUs: 5e8},
+		{cpus: "-1", expectError: true},
+		{cpus: "two", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cpus, func(t *testing.T) {
+			nanoCPUs, err := parseCPUs(tt.cpus)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.nanoCPUs, nanoCPUs)
+		})
+	}
+}
+
+func Test_parseMemory(t *testing.T) {
+	tests := []struct {
+		memory      string
+		bytes       int64
+		expectError bool
+	}{
+		{memory: "", bytes: 0},
+		{memory: "512m", bytes: 512 * 1024 * 1024},
+		{memory: "4g", bytes: 4 * 1024 * 1024 * 1024},
+		{memory: "4GiB", bytes: 4 * 1024 * 1024 * 1024},
+		{memory: "lots", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.memory, func(t *testing.T) {
+			bytes, err := parseMemory(tt.memory)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.bytes, bytes)
+		})
+	}
+}
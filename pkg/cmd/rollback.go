@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// rollbackStep undoes a completed step of creating an instance using the runtime it is passed
+type rollbackStep struct {
+	description string
+	undo        func(r runtime.Runtime) error
+}
+
+// transaction records the completed steps of creating an instance, so they can be undone in reverse order
+// if a later step fails. Steps are not recorded on a nil transaction
+type transaction struct {
+	steps []rollbackStep
+}
+
+// record adds a completed step to the transaction, along with the function to undo it
+func (t *transaction) record(description string, undo func(r runtime.Runtime) error) {
+	if t == nil {
+		return
+	}
+	t.steps = append(t.steps, rollbackStep{description: description, undo: undo})
+}
+
+// rollback undoes all recorded steps in reverse order using r. All steps are attempted even if some fail,
+// and the errors of failed steps are returned
+func (t *transaction) rollback(r runtime.Runtime) error {
+	var errs []error
+	for i := len(t.steps) - 1; i >= 0; i-- {
+		step := t.steps[i]
+		logrus.Infof("rolling back %s", step.description)
+		if err := step.undo(r); err != nil {
+			errs = append(errs, fmt.Errorf("error rolling back %s: %w", step.description, err))
+		}
+	}
+	t.steps = nil
+	return errors.Join(errs...)
+}
+
+// Create creates a new instance and waits for it to become ready. If any step fails, or create is interrupted,
+// the steps completed so far are undone in reverse order so no partial instance is left behind, unless
+// KeepOnFailure is set to retain them for debugging
+func (s *Simulator) Create() error {
+	s.tx = &transaction{}
+	defer func() { s.tx = nil }()
+
+	err := s.CreateNewInstance()
+	if err == nil && s.Ctx.Err() != nil {
+		err = fmt.Errorf("interrupted while creating instance %s: %w", s.Name, s.Ctx.Err())
+	}
+	if err == nil {
+		err = s.WaitForReady(s.WaitTimeout)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if s.KeepOnFailure {
+		logrus.Warnf("keeping resources created for instance %s for debugging, remove them with sim-cli delete --name %s", s.Name, s.Name)
+		return err
+	}
+
+	// the runtime is not cancelled with the command, so interrupted or timed out creates are still rolled back
+	logrus.Infof("create failed, rolling back instance %s", s.Name)
+	if rollbackErr := s.tx.rollback(s.Runtime.WithContext(context.WithoutCancel(s.Ctx))); rollbackErr != nil {
+		return fmt.Errorf("%w\nerror rolling back instance %s, remove it with sim-cli delete --name %s: %w", err, s.Name, s.Name, rollbackErr)
+	}
+	return err
+}
+
+// removeUnusedVolume removes a bundle volume unless it is mounted by another instance, as volumes are
+// shared by instances created from the same bundle
+func removeUnusedVolume(r runtime.Runtime, volume string) error {
+	containers, err := r.FindAllSimManagedContainers()
+	if err != nil {
+		return fmt.Errorf("error listing containers: %w", err)
+	}
+
+	for _, v := range containers {
+		if v.Labels[runtime.BundleVolumeLabel] == volume {
+			logrus.Infof("volume %s is still used by instance %s", volume, v.Labels[runtime.InstanceLabel])
+			return nil
+		}
+	}
+	return r.RemoveVolume(volume)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/kubeconfig"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime/fake"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_transactionRollback(t *testing.T) {
+	assert := require.New(t)
+	var undone []string
+	tx := &transaction{}
+	for _, v := range []string{"first", "second", "third"} {
+		step := v
+		tx.record(step, func(runtime.Runtime) error {
+			undone = append(undone, step)
+			if step == "second" {
+				return errInjected
+			}
+			return nil
+		})
+	}
+
+	err := tx.rollback(nil)
+	assert.ErrorIs(err, errInjected)
+	assert.ErrorContains(err, "error rolling back second")
+	assert.Equal([]string{"third", "second", "first"}, undone, "expected all steps to be undone in reverse order")
+	assert.NoError(tx.rollback(nil), "expected steps to be undone only once")
+
+	// steps are ignored when no transaction is in progress
+	var none *transaction
+	none.record("ignored", func(runtime.Runtime) error { return errInjected })
+}
+
+func Test_CreateRollback(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		setup func(s *Simulator, r *fake.Runtime)
+	}{
+		{
+			name: "container fails to run",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				r.Errors["RunContainer"] = errInjected
+			},
+		},
+		{
+			name: "container fails to run in mount mode",
+			mode: ModeMount,
+			setup: func(s *Simulator, r *fake.Runtime) {
+				r.Errors["RunContainer"] = errInjected
+			},
+		},
+		{
+			name: "kubeconfig export fails",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				r.Errors["QueryExposedMapping"] = errInjected
+			},
+		},
+		{
+			name: "api server does not become ready",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				s.WaitTimeout = 0
+			},
+		},
+		{
+			name: "interrupted",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				ctx, cancel := context.WithCancel(s.Ctx)
+				cancel()
+				s.Ctx = ctx
+			},
+		},
+		{
+			name: "interrupted while running container",
+			setup: func(s *Simulator, r *fake.Runtime) {
+				ctx, cancel := context.WithCancel(s.Ctx)
+				s.Ctx = ctx
+				r.Ctx = ctx
+				r.OnRun = func(*fake.Container) { cancel() }
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			s, r := newTestSimulator(t)
+			if tt.mode != "" {
+				s.Mode = tt.mode
+			}
+			tt.setup(s, r)
+			assert.NoError(s.PreFlightChecks())
+			assert.Error(s.Create())
+			assert.Empty(r.Containers, "expected container to be removed")
+			assert.Empty(r.Images, "expected image to be removed")
+			assert.Empty(r.Volumes, "expected volume to be removed")
+			kubeConfigPath, err := simKubeConfigPath()
+			assert.NoError(err)
+			contexts, err := kubeconfig.Contexts(kubeConfigPath)
+			assert.NoError(err)
+			assert.NotContains(contexts, s.Name, "expected context to be removed")
+			assert.Nil(s.tx)
+
+			// instance can be created again after rollback
+			r.Errors = make(map[string]error)
+			r.OnRun = nil
+			s.Ctx = context.TODO()
+			assert.NoError(s.PreFlightChecks())
+			assert.NoError(s.CreateNewInstance())
+		})
+	}
+}
+
+func Test_CreateKeepOnFailure(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.KeepOnFailure = true
+	s.WaitTimeout = 0
+	assert.Error(s.Create())
+	assert.Contains(r.Containers, s.Name)
+	assert.Contains(r.Images, s.Name)
+	assert.Contains(loadSimKubeConfig(t), s.Name)
+}
+
+func Test_CreateRollbackSharedVolume(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	s.Mode = ModeMount
+	assert.NoError(s.CreateNewInstance())
+
+	second := *s
+	second.Name = "issue-7007-retry"
+	r.Errors["RunContainer"] = errInjected
+	assert.ErrorIs(second.Create(), errInjected)
+	assert.Len(r.Volumes, 1, "expected volume used by existing instance to be retained")
+	assert.Len(r.Containers, 1)
+}
+
+func Test_CreateRollbackFails(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	r.Errors["QueryExposedMapping"] = errInjected
+	removeErr := errors.New("remove failed")
+	r.Errors["RemoveImages"] = removeErr
+
+	err := s.Create()
+	assert.ErrorIs(err, errInjected, "expected create error to be returned")
+	assert.ErrorIs(err, removeErr, "expected rollback error to be returned")
+	assert.ErrorContains(err, "sim-cli delete --name "+s.Name)
+	assert.Empty(r.Containers, "expected remaining steps to be rolled back")
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/docker"
+	"github.com/ibrokethecloud/sim-cli/pkg/podman"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+)
+
+// newRuntime initialises the container runtime identified by name, connecting to the daemon identified by opts
+func newRuntime(ctx context.Context, name string, opts docker.ClientOptions) (runtime.Runtime, error) {
+	switch name {
+	case runtime.Docker:
+		return docker.NewClient(ctx, opts)
+	case runtime.Podman:
+		return podman.NewClient(ctx, opts)
+	default:
+		return nil, fmt.Errorf("unsupported runtime %s, supported runtimes are %s and %s", name, runtime.Docker, runtime.Podman)
+	}
+}
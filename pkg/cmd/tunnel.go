@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/docker/cli/cli/connhelper/ssh"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// localhostAddress is the address tunnels listen on locally
+	localhostAddress = "127.0.0.1"
+)
+
+// sshCommand is the ssh client used to forward ports from remote docker hosts
+var sshCommand = "ssh"
+
+// Tunnel forwards localPort to the simulator api server over ssh, for instances running on a docker host
+// accessed over ssh://, and points the kubeconfig context for the instance at the forwarded port.
+// A free local port is selected when localPort is 0. Tunnel blocks until the ssh connection is closed
+func (s *Simulator) Tunnel(localPort int) error {
+	instance, err := s.findInstance()
+	if err != nil {
+		return err
+	}
+
+	mapping, ok := runtime.PublishedPort(instance.Ports)
+	if !ok {
+		return fmt.Errorf("instance %s is in state %s, ensure the instance is running: %w", s.Name, runtime.ContainerState(instance), runtime.ErrNoPublishedPort)
+	}
+
+	host := s.Runtime.DaemonHost()
+	spec, err := ssh.ParseURL(host)
+	if err != nil {
+		return fmt.Errorf("tunnel is only supported for ssh:// docker hosts, unable to use %s: %w", host, err)
+	}
+
+	if localPort == 0 {
+		localPort, err = freeLocalPort()
+		if err != nil {
+			return err
+		}
+	}
+
+	args := append(tunnelArgs(localPort, mapping.IP, int(mapping.PublicPort)), spec.Args()...)
+	logrus.Debugf("starting ssh tunnel with args %v", args)
+	cmd := exec.CommandContext(s.Ctx, sshCommand, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting ssh tunnel for instance %s: %w", s.Name, err)
+	}
+
+	if err := s.exportKubeConfig(localhostAddress, strconv.Itoa(localPort)); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	logrus.Infof("forwarding 127.0.0.1:%d to instance %s on %s, press ctrl-c to close the tunnel", localPort, s.Name, spec.Host)
+	// the tunnel is killed when the command is interrupted or times out, which closes the tunnel as expected
+	if err := cmd.Wait(); err != nil && s.Ctx.Err() == nil {
+		return fmt.Errorf("ssh tunnel for instance %s exited: %w", s.Name, err)
+	}
+	logrus.Infof("ssh tunnel for instance %s closed, run sim-cli export --name %s to point the kubeconfig context at the remote host", s.Name, s.Name)
+	return nil
+}
+
+// tunnelArgs returns the ssh arguments to forward localPort to the api server published on bindAddress and port
+// of the remote host. Ports published on all addresses are forwarded over the remote loopback address
+func tunnelArgs(localPort int, bindAddress string, port int) []string {
+	remoteAddress := bindAddress
+	if ip := net.ParseIP(bindAddress); ip == nil || ip.IsUnspecified() {
+		remoteAddress = localhostAddress
+	}
+
+	forward := fmt.Sprintf("%s:%d:%s:%d", localhostAddress, localPort, remoteAddress, port)
+	if ip := net.ParseIP(remoteAddress); ip != nil && ip.To4() == nil {
+		forward = fmt.Sprintf("%s:%d:[%s]:%d", localhostAddress, localPort, remoteAddress, port)
+	}
+	return []string{"-N", "-o", "ExitOnForwardFailure=yes", "-L", forward}
+}
+
+// freeLocalPort returns a port which is not in use on the loopback address
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(localhostAddress, "0"))
+	if err != nil {
+		return 0, fmt.Errorf("error finding free local port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
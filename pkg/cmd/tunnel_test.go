@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSSH replaces the ssh client with a script which records its arguments to a file, and exits with exitCode
+func stubSSH(t *testing.T, exitCode int) string {
+	t.Helper()
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "ssh")
+	contents := "#!/bin/sh\necho \"$@\" > " + argsFile + "\nexit " + strconv.Itoa(exitCode) + "\n"
+	require.NoError(t, os.WriteFile(script, []byte(contents), 0700))
+
+	original := sshCommand
+	sshCommand = script
+	t.Cleanup(func() { sshCommand = original })
+	return argsFile
+}
+
+func Test_Tunnel(t *testing.T) {
+	assert := require.New(t)
+	s, r := newTestSimulator(t)
+	assert.Error(s.Tunnel(0), "expected error for missing instance")
+	assert.NoError(s.CreateNewInstance())
+	assert.ErrorContains(s.Tunnel(0), "only supported for ssh:// docker hosts")
+
+	argsFile := stubSSH(t, 0)
+	r.Host = "ssh://admin@docker.example.com:2222"
+	assert.NoError(s.Tunnel(36443))
+	args, err := os.ReadFile(argsFile)
+	assert.NoError(err)
+	assert.Equal("-N -o ExitOnForwardFailure=yes -L 127.0.0.1:36443:127.0.0.1:32768 -l admin -p 2222 -- docker.example.com\n", string(args))
+	assert.Equal("https://127.0.0.1:36443", loadSimKubeConfig(t)[s.Name])
+
+	stubSSH(t, 1)
+	assert.ErrorContains(s.Tunnel(0), "ssh tunnel for instance issue-7007 exited")
+
+	assert.NoError(s.StopInstance())
+	assert.ErrorIs(s.Tunnel(0), runtime.ErrNoPublishedPort)
+}
+
+func Test_tunnelArgs(t *testing.T) {
+	tests := []struct {
+		bindAddress string
+		forward     string
+	}{
+		{bindAddress: "0.0.0.0", forward: "127.0.0.1:30000:127.0.0.1:6443"},
+		{bindAddress: "", forward: "127.0.0.1:30000:127.0.0.1:6443"},
+		{bindAddress: "127.0.0.1", forward: "127.0.0.1:30000:127.0.0.1:6443"},
+		{bindAddress: "10.0.0.5", forward: "127.0.0.1:30000:10.0.0.5:6443"},
+		{bindAddress: "fd00::5", forward: "127.0.0.1:30000:[fd00::5]:6443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bindAddress, func(t *testing.T) {
+			args := tunnelArgs(30000, tt.bindAddress, 6443)
+			require.Equal(t, tt.forward, args[len(args)-1])
+		})
+	}
+}
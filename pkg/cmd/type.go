@@ -2,16 +2,44 @@ package cmd
 
 import (
 	"context"
+	"time"
 
-	"github.com/ibrokethecloud/sim-cli/pkg/docker"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+)
+
+const (
+	// ModeImage builds an image per instance with the bundle packaged in it
+	ModeImage = "image"
+	// ModeMount mounts the bundle from a volume into a container running the base image
+	ModeMount = "mount"
+
+	// PullAlways pulls the base image before creating an instance
+	PullAlways = "always"
+	// PullMissing pulls the base image only if it is not present locally
+	PullMissing = "missing"
+	// PullNever never pulls the base image, and requires it to be present locally
+	PullNever = "never"
 )
 
 type Simulator struct {
-	Name         string
-	BundlePath   string
-	Status       string
-	Port         int
-	Ctx          context.Context
-	Image        string
-	DockerClient docker.Client
+	Name        string
+	BundlePath  string
+	Status      string
+	Port        int
+	HostPort    int
+	PortRange   string
+	BindAddress string
+	Ctx         context.Context
+	Image       string
+	Mode        string
+	PullPolicy  string
+	CPUs        string
+	Memory      string
+	WaitTimeout time.Duration
+	// KeepOnFailure retains the resources of an instance which failed to be created, instead of rolling them back
+	KeepOnFailure bool
+	Runtime       runtime.Runtime
+
+	// tx records the steps completed while creating an instance
+	tx *transaction
 }
@@ -0,0 +1,234 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// BundleFormat identifies how a support bundle is packaged
+type BundleFormat string
+
+const (
+	BundleZip       BundleFormat = "zip"
+	BundleTar       BundleFormat = "tar"
+	BundleTarGzip   BundleFormat = "tar.gz"
+	BundleTarZstd   BundleFormat = "tar.zst"
+	BundleDirectory BundleFormat = "directory"
+
+	// tarMagicOffset is the offset of the ustar magic in the header of a tar archive
+	tarMagicOffset = 257
+	// formatHeaderSize is the number of bytes read from a bundle to detect its format
+	formatHeaderSize = 512
+)
+
+var (
+	// ErrUnsupportedBundle is returned for bundles which are not a zip, tar, tar.gz or tar.zst file, or a directory
+	ErrUnsupportedBundle = errors.New("unsupported bundle format")
+
+	zipMagic      = []byte("PK\x03\x04")
+	emptyZipMagic = []byte("PK\x05\x06")
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zstdMagic     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	tarMagic      = []byte("ustar")
+)
+
+// bundleEntry is a file or directory in a support bundle, independent of how the bundle is packaged
+type bundleEntry struct {
+	// name is the slash separated path of the entry in the bundle
+	name    string
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+	// open returns the contents of regular files, and is only valid until the next entry is walked
+	open func() (io.ReadCloser, error)
+}
+
+// DetectBundleFormat identifies the format of the bundle at bundlePath from its leading magic bytes,
+// as bundles are often renamed or re-packed, so the extension can not be relied upon
+func DetectBundleFormat(bundlePath string) (BundleFormat, error) {
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		return "", fmt.Errorf("error checking bundle path %s: %w", bundlePath, err)
+	}
+
+	if info.IsDir() {
+		return BundleDirectory, nil
+	}
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return "", fmt.Errorf("error opening bundle %s: %w", bundlePath, err)
+	}
+	defer f.Close()
+
+	header := make([]byte, formatHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("error reading bundle %s: %w", bundlePath, err)
+	}
+
+	format, err := detectFormat(header[:n])
+	if err != nil {
+		return "", fmt.Errorf("error identifying bundle %s: %w", bundlePath, err)
+	}
+	return format, nil
+}
+
+// detectFormat identifies the format of a bundle from the first bytes of the file. Compressed files
+// are expected to contain a tar archive
+func detectFormat(header []byte) (BundleFormat, error) {
+	switch {
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, emptyZipMagic):
+		return BundleZip, nil
+	case bytes.HasPrefix(header, gzipMagic):
+		return BundleTarGzip, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		return BundleTarZstd, nil
+	case len(header) >= tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return BundleTar, nil
+	}
+	return "", fmt.Errorf("%w, bundles must be a zip, tar, tar.gz or tar.zst file, or a directory", ErrUnsupportedBundle)
+}
+
+// walkBundle calls fn for each entry in the bundle at bundlePath packaged in format, in the order the
+// entries are stored. Reading the bundle stops once ctx is cancelled
+func walkBundle(ctx context.Context, bundlePath string, format BundleFormat, fn func(e bundleEntry) error) error {
+	switch format {
+	case BundleDirectory:
+		return walkDirectory(bundlePath, fn)
+	case BundleZip:
+		return walkZip(bundlePath, fn)
+	}
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return fmt.Errorf("error opening bundle %s: %w", bundlePath, err)
+	}
+	defer f.Close()
+
+	r := io.Reader(&contextReader{ctx: ctx, r: f})
+	switch format {
+	case BundleTarGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("error decompressing bundle %s: %w", bundlePath, err)
+		}
+		defer gr.Close()
+		r = gr
+	case BundleTarZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("error decompressing bundle %s: %w", bundlePath, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return walkTar(r, fn)
+}
+
+// walkZip calls fn for each entry in the zip file at bundlePath
+func walkZip(bundlePath string, fn func(e bundleEntry) error) error {
+	r, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if err := fn(bundleEntry{
+			name:    f.Name,
+			mode:    f.Mode(),
+			size:    int64(f.UncompressedSize64),
+			modTime: f.Modified,
+			open:    f.Open,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar calls fn for each entry in the tar archive read from r
+func walkTar(r io.Reader, fn func(e bundleEntry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar archive: %w", err)
+		}
+
+		if err := fn(bundleEntry{
+			name:    hdr.Name,
+			mode:    hdr.FileInfo().Mode(),
+			size:    hdr.Size,
+			modTime: hdr.ModTime,
+			open:    func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// walkDirectory calls fn for each file and directory below bundlePath in lexical order, for bundles
+// which have already been extracted
+func walkDirectory(bundlePath string, fn func(e bundleEntry) error) error {
+	fsys := os.DirFS(bundlePath)
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// the bundle directory itself is not part of the bundle
+		if name == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(bundleEntry{
+			name:    name,
+			mode:    info.Mode(),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			open:    func() (io.ReadCloser, error) { return fsys.Open(name) },
+		})
+	})
+}
+
+// BundleSize returns the size of the bundle file, or the total size of all files in a bundle directory
+func BundleSize(bundlePath string) (int64, error) {
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		return 0, fmt.Errorf("error checking bundle path %s: %w", bundlePath, err)
+	}
+
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	var size int64
+	if err := walkDirectory(bundlePath, func(e bundleEntry) error {
+		if e.mode.IsRegular() {
+			size += e.size
+		}
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("error checking size of bundle %s: %w", bundlePath, err)
+	}
+	return size, nil
+}
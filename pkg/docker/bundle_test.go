@@ -0,0 +1,294 @@
+package docker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+// nopWriteCloser writes an uncompressed tar archive
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeTestTar generates a tar archive containing files, keyed by name, compressed by the writer returned by compress
+func writeTestTar(t *testing.T, files map[string]string, compress func(w io.Writer) io.WriteCloser) string {
+	t.Helper()
+	tarFile := filepath.Join(t.TempDir(), "supportbundle_test.bin")
+	f, err := os.Create(tarFile)
+	require.NoError(t, err)
+	defer f.Close()
+
+	cw := compress(f)
+	tw := tar.NewWriter(cw)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(files[name]))}
+		if strings.HasSuffix(name, "/") {
+			hdr = &tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755}
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err = tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, cw.Close())
+	return tarFile
+}
+
+// writeTestDirectory extracts files, keyed by name, into a temp directory
+func writeTestDirectory(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			require.NoError(t, os.MkdirAll(p, 0755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0644))
+	}
+	return dir
+}
+
+func Test_DetectBundleFormat(t *testing.T) {
+	files := map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	}
+	tests := []struct {
+		name        string
+		bundlePath  func(t *testing.T) string
+		expected    BundleFormat
+		expectError bool
+	}{
+		{
+			name:       "zip",
+			bundlePath: func(t *testing.T) string { return writeTestZip(t, files) },
+			expected:   BundleZip,
+		},
+		{
+			name: "tar",
+			bundlePath: func(t *testing.T) string {
+				return writeTestTar(t, files, func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} })
+			},
+			expected: BundleTar,
+		},
+		{
+			name: "tar.gz",
+			bundlePath: func(t *testing.T) string {
+				return writeTestTar(t, files, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+			},
+			expected: BundleTarGzip,
+		},
+		{
+			name: "tar.zst",
+			bundlePath: func(t *testing.T) string {
+				return writeTestTar(t, files, func(w io.Writer) io.WriteCloser {
+					zw, err := zstd.NewWriter(w)
+					require.NoError(t, err)
+					return zw
+				})
+			},
+			expected: BundleTarZstd,
+		},
+		{
+			name:       "directory",
+			bundlePath: func(t *testing.T) string { return writeTestDirectory(t, files) },
+			expected:   BundleDirectory,
+		},
+		{
+			name: "unsupported file",
+			bundlePath: func(t *testing.T) string {
+				p := filepath.Join(t.TempDir(), "supportbundle_test.zip")
+				require.NoError(t, os.WriteFile(p, []byte("not a bundle"), 0600))
+				return p
+			},
+			expectError: true,
+		},
+		{
+			name: "empty file",
+			bundlePath: func(t *testing.T) string {
+				p := filepath.Join(t.TempDir(), "supportbundle_test.zip")
+				require.NoError(t, os.WriteFile(p, nil, 0600))
+				return p
+			},
+			expectError: true,
+		},
+		{
+			name:        "missing file",
+			bundlePath:  func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing.zip") },
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := DetectBundleFormat(tt.bundlePath(t))
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, format)
+		})
+	}
+	_, err := detectFormat([]byte("not a bundle"))
+	require.ErrorIs(t, err, ErrUnsupportedBundle)
+}
+
+func Test_BuildBundleTarFormats(t *testing.T) {
+	files := map[string]string{
+		"supportbundle_test/metadata.yaml":       "metadata",
+		"supportbundle_test/logs/simulator.log":  "log",
+		"supportbundle_test/yamls/cluster.yaml":  "cluster",
+		"supportbundle_test/yamls/namespaced/a/": "",
+	}
+	expected := map[string]string{
+		"bundle/metadata.yaml":      "metadata",
+		"bundle/logs/simulator.log": "log",
+		"bundle/yamls/cluster.yaml": "cluster",
+	}
+	gzipWriter := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+
+	tests := []struct {
+		name       string
+		bundlePath func(t *testing.T) string
+	}{
+		{
+			name: "tar",
+			bundlePath: func(t *testing.T) string {
+				return writeTestTar(t, files, func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} })
+			},
+		},
+		{
+			name:       "tar.gz",
+			bundlePath: func(t *testing.T) string { return writeTestTar(t, files, gzipWriter) },
+		},
+		{
+			name: "tar.gz created from within the bundle directory",
+			bundlePath: func(t *testing.T) string {
+				relative := map[string]string{"./": ""}
+				for name, contents := range files {
+					relative["./"+name] = contents
+				}
+				return writeTestTar(t, relative, gzipWriter)
+			},
+		},
+		{
+			name: "tar.zst",
+			bundlePath: func(t *testing.T) string {
+				return writeTestTar(t, files, func(w io.Writer) io.WriteCloser {
+					zw, err := zstd.NewWriter(w)
+					require.NoError(t, err)
+					return zw
+				})
+			},
+		},
+		{
+			name: "directory containing the extracted bundle",
+			bundlePath: func(t *testing.T) string {
+				return filepath.Join(writeTestDirectory(t, files), "supportbundle_test")
+			},
+		},
+		{
+			name:       "directory containing the bundle directory",
+			bundlePath: func(t *testing.T) string { return writeTestDirectory(t, files) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundleTar := BuildBundleTar(context.TODO(), tt.bundlePath(t))
+			defer bundleTar.Close()
+			require.Equal(t, expected, readTestTar(t, bundleTar))
+		})
+	}
+}
+
+func Test_BundleDigestDirectory(t *testing.T) {
+	assert := require.New(t)
+	dir := writeTestDirectory(t, map[string]string{
+		"metadata.yaml":      "metadata",
+		"logs/simulator.log": "log",
+	})
+
+	digest, err := BundleDigest(context.TODO(), dir)
+	assert.NoError(err)
+	assert.Len(digest, 64)
+	again, err := BundleDigest(context.TODO(), dir)
+	assert.NoError(err)
+	assert.Equal(digest, again, "expected digest to be stable")
+
+	size, err := BundleSize(dir)
+	assert.NoError(err)
+	assert.Equal(int64(len("metadata")+len("log")), size)
+
+	assert.NoError(os.WriteFile(filepath.Join(dir, "logs", "simulator.log"), []byte("new"), 0644))
+	changed, err := BundleDigest(context.TODO(), dir)
+	assert.NoError(err)
+	assert.NotEqual(digest, changed, "expected digest to change with the contents of the bundle")
+}
+
+func Test_BuildBundleTarSubdirectoryFirst(t *testing.T) {
+	// entries of tar -C supportbundle_test . start with a subdirectory of the bundle
+	files := map[string]string{
+		"./":              "",
+		"./logs/":         "",
+		"./logs/a.log":    "log",
+		"./metadata.yaml": "metadata",
+	}
+	zipFile := writeTestZip(t, map[string]string{
+		"logs/a.log":    "log",
+		"metadata.yaml": "metadata",
+	})
+	zipTar := BuildBundleTar(context.TODO(), zipFile)
+	defer zipTar.Close()
+	expected := readTestTar(t, zipTar)
+	require.Equal(t, map[string]string{"bundle/logs/a.log": "log", "bundle/metadata.yaml": "metadata"}, expected)
+
+	tests := []struct {
+		name     string
+		compress func(w io.Writer) io.WriteCloser
+	}{
+		{
+			name:     "tar",
+			compress: func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} },
+		},
+		{
+			name:     "tar.gz",
+			compress: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		},
+		{
+			name: "tar.zst",
+			compress: func(w io.Writer) io.WriteCloser {
+				zw, err := zstd.NewWriter(w)
+				require.NoError(t, err)
+				return zw
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundleTar := BuildBundleTar(context.TODO(), writeTestTar(t, files, tt.compress))
+			defer bundleTar.Close()
+			require.Equal(t, expected, readTestTar(t, bundleTar), "expected same layout as the zip bundle")
+		})
+	}
+}
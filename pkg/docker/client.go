@@ -5,26 +5,51 @@ import (
 	"fmt"
 
 	"github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/config/configfile"
 	"github.com/docker/cli/cli/context/docker"
 	"github.com/docker/cli/cli/flags"
 	"github.com/docker/docker/client"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 )
 
 const (
-	bundleNameKey     = "harvesterhci.io/bundle-name"
 	simKubeConfigPath = "/root/.sim/admin.kubeconfig"
 )
 
+// ensure Client can be used as a container runtime
+var _ runtime.Runtime = &Client{}
+
 type Client struct {
 	APIClient client.APIClient
 	Endpoint  docker.Endpoint
-	ctx       context.Context
+	// ConfigFile is the docker cli config, and provides registry credentials used to pull images
+	ConfigFile      *configfile.ConfigFile
+	ImageRepository string
+	ctx             context.Context
+}
+
+// ClientOptions allow customising the daemon the client connects to
+type ClientOptions struct {
+	// Host overrides the docker endpoint, and allows connecting to docker compatible api's like podman
+	Host string
+	// Context is the name of the docker context to use, instead of the current docker context
+	Context string
+	// TLS enables tls when connecting to the daemon, and is implied by TLSVerify
+	TLS bool
+	// TLSVerify enables tls and verifies the daemon certificate
+	TLSVerify bool
+	// TLSCACert, TLSCert and TLSKey are the paths to tls files, and default to the docker cli cert path
+	TLSCACert string
+	TLSCert   string
+	TLSKey    string
+	// ImageRepository is the repository used to tag images built by sim-cli, defaults to sim-cli-managed
+	ImageRepository string
 }
 
 // GetClient leverages dockerCli to handle interaction with the docker client
-func GetClient() (*command.DockerCli, error) {
+func GetClient(opts ClientOptions) (*command.DockerCli, error) {
 	dockerCli, err := command.NewDockerCli()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new docker CLI with standard streams: %w", err)
@@ -33,8 +58,13 @@ func GetClient() (*command.DockerCli, error) {
 	newClientOpts := flags.NewClientOptions()
 	newClientOpts.LogLevel = logrus.GetLevel().String()
 
+	// options are passed as docker cli flags to ensure defaults like DOCKER_CERT_PATH are handled the same
+	// way as the docker cli
 	flagset := pflag.NewFlagSet("docker", pflag.ContinueOnError)
 	newClientOpts.InstallFlags(flagset)
+	if err := flagset.Parse(opts.flags()); err != nil {
+		return nil, fmt.Errorf("error parsing docker client options: %w", err)
+	}
 	newClientOpts.SetDefaultOptions(flagset)
 
 	err = dockerCli.Initialize(newClientOpts)
@@ -45,16 +75,64 @@ func GetClient() (*command.DockerCli, error) {
 	return dockerCli, nil
 }
 
+// flags returns the docker cli flags equivalent to the options
+func (o ClientOptions) flags() []string {
+	var args []string
+	for flag, value := range map[string]string{
+		"host":      o.Host,
+		"context":   o.Context,
+		"tlscacert": o.TLSCACert,
+		"tlscert":   o.TLSCert,
+		"tlskey":    o.TLSKey,
+	} {
+		if value != "" {
+			args = append(args, fmt.Sprintf("--%s=%s", flag, value))
+		}
+	}
+
+	if o.TLS {
+		args = append(args, "--tls")
+	}
+	if o.TLSVerify {
+		args = append(args, "--tlsverify")
+	}
+	return args
+}
+
 // NewClient initialises a new client for interacting with dockerd
-func NewClient(ctx context.Context) (*Client, error) {
-	dockerCli, err := GetClient()
+func NewClient(ctx context.Context, opts ClientOptions) (*Client, error) {
+	dockerCli, err := GetClient(opts)
 	if err != nil {
 		return nil, err
 	}
+
+	if opts.ImageRepository == "" {
+		opts.ImageRepository = simCliPrefix
+	}
+
 	c := &Client{
-		APIClient: dockerCli.Client(),
-		Endpoint:  dockerCli.DockerEndpoint(),
-		ctx:       ctx,
+		APIClient:       dockerCli.Client(),
+		Endpoint:        dockerCli.DockerEndpoint(),
+		ConfigFile:      dockerCli.ConfigFile(),
+		ImageRepository: opts.ImageRepository,
+		ctx:             ctx,
 	}
 	return c, nil
 }
+
+// WithContext returns a copy of the client which uses ctx for all operations
+func (c *Client) WithContext(ctx context.Context) runtime.Runtime {
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+// imageName returns the name of the image associated with instanceName
+func (c *Client) imageName(instanceName string) string {
+	return fmt.Sprintf("%s:%s", c.ImageRepository, instanceName)
+}
+
+// DaemonHost returns the address of the docker daemon the client is connected to
+func (c *Client) DaemonHost() string {
+	return c.Endpoint.Host
+}
@@ -1,14 +1,42 @@
 package docker
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func Test_GetClient(t *testing.T) {
-	cli, err := GetClient()
+	cli, err := GetClient(ClientOptions{})
 	assert := require.New(t)
 	assert.NoError(err)
 	assert.NotNil(cli)
 }
+
+func Test_ClientOptionsFlags(t *testing.T) {
+	assert := require.New(t)
+	assert.Empty(ClientOptions{}.flags())
+	assert.ElementsMatch([]string{"--host=tcp://10.0.0.5:2376", "--tlscacert=/certs/ca.pem", "--tlsverify"},
+		ClientOptions{Host: "tcp://10.0.0.5:2376", TLSCACert: "/certs/ca.pem", TLSVerify: true}.flags())
+	assert.ElementsMatch([]string{"--context=remote", "--tls"}, ClientOptions{Context: "remote", TLS: true}.flags())
+}
+
+func Test_GetClientWithHost(t *testing.T) {
+	assert := require.New(t)
+	cli, err := GetClient(ClientOptions{Host: "tcp://10.0.0.5:2376"})
+	assert.NoError(err)
+	assert.Equal("tcp://10.0.0.5:2376", cli.DockerEndpoint().Host)
+}
+
+func Test_WithContext(t *testing.T) {
+	assert := require.New(t)
+	cli, err := NewClient(context.TODO(), ClientOptions{})
+	assert.NoError(err)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	withCtx, ok := cli.WithContext(ctx).(*Client)
+	assert.True(ok)
+	assert.Equal(ctx, withCtx.ctx)
+	assert.Equal(context.TODO(), cli.ctx, "expected context of the original client to be unchanged")
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
+)
+
+// Exec runs a command in the running container associated with instanceName, attaching stdin and output streams,
+// and returns the exit code of the command
+func (c *Client) Exec(instanceName string, opts runtime.ExecOptions) (int, error) {
+	containers, err := c.FindRunningContainer(instanceName)
+	if err != nil {
+		return 0, fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
+	}
+
+	if len(containers) != 1 {
+		return 0, fmt.Errorf("expected one running container for instance %s, got %d", instanceName, len(containers))
+	}
+
+	execOpts := container.ExecOptions{
+		Cmd:          opts.Cmd,
+		Tty:          opts.TTY,
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	if opts.Height != 0 && opts.Width != 0 {
+		execOpts.ConsoleSize = &[2]uint{opts.Height, opts.Width}
+	}
+
+	exec, err := c.APIClient.ContainerExecCreate(c.ctx, containers[0].ID, execOpts)
+	if err != nil {
+		return 0, fmt.Errorf("error creating exec in container %s: %w", instanceName, err)
+	}
+
+	resp, err := c.APIClient.ContainerExecAttach(c.ctx, exec.ID, container.ExecAttachOptions{
+		Tty:         opts.TTY,
+		ConsoleSize: execOpts.ConsoleSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error attaching to exec in container %s: %w", instanceName, err)
+	}
+	defer resp.Close()
+
+	if opts.Stdin != nil {
+		go func() {
+			if _, err := io.Copy(resp.Conn, opts.Stdin); err != nil {
+				logrus.WithError(err).Debug("error copying stdin to exec")
+			}
+			resp.CloseWrite()
+		}()
+	}
+
+	// output of commands with a tty is not multiplexed
+	if opts.TTY {
+		_, err = io.Copy(opts.Stdout, resp.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(opts.Stdout, opts.Stderr, resp.Reader)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading output from exec in container %s: %w", instanceName, err)
+	}
+
+	result, err := c.APIClient.ContainerExecInspect(c.ctx, exec.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error inspecting exec in container %s: %w", instanceName, err)
+	}
+	return result.ExitCode, nil
+}
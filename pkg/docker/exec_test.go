@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Exec(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	api.addContainer("running-id", "issue-7007", "running")
+	api.addContainer("exited-id", "issue-113", "exited")
+
+	stdout := new(bytes.Buffer)
+	code, err := client.Exec("issue-7007", runtime.ExecOptions{Cmd: []string{"ls", "/bundle"}, Stdout: stdout, Stderr: stdout})
+	assert.NoError(err)
+	assert.Equal(0, code)
+	assert.Equal("ls /bundle\n", stdout.String())
+
+	stdout.Reset()
+	api.execExitCode = 127
+	code, err = client.Exec("issue-7007", runtime.ExecOptions{
+		Cmd:    []string{"bash"},
+		TTY:    true,
+		Height: 24,
+		Width:  80,
+		Stdin:  strings.NewReader("exit\n"),
+		Stdout: stdout,
+		Stderr: stdout,
+	})
+	assert.NoError(err)
+	assert.Equal(127, code)
+	assert.Equal("bash\n", stdout.String())
+	opts := api.execs["running-id-exec-1"]
+	assert.True(opts.Tty)
+	assert.True(opts.AttachStdin)
+	assert.Equal(&[2]uint{24, 80}, opts.ConsoleSize)
+
+	_, err = client.Exec("issue-113", runtime.ExecOptions{Cmd: []string{"ls"}, Stdout: stdout, Stderr: stdout})
+	assert.Error(err, "expected error for exited instance")
+}
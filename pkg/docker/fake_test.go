@@ -0,0 +1,485 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeAPIClient implements the subset of the docker api used by sim-cli in memory. Calls to
+// any other api will panic
+type fakeAPIClient struct {
+	client.APIClient
+	images  []*image.Summary
+	builds  int
+	volumes []*volume.Volume
+	// pulls records the registry auth used for each pulled reference
+	pulls      map[string]string
+	containers []*types.Container
+	// logs are keyed by container ID, and are multiplexed in the stream returned by ContainerLogs
+	stdout map[string]string
+	stderr map[string]string
+	// execs records the options of commands run in containers keyed by exec ID, and execs exit with execExitCode
+	execs        map[string]container.ExecOptions
+	execExitCode int
+	// volumeFiles records the names of files copied into each volume, keyed by volume name
+	volumeFiles map[string][]string
+	// onCopy is called before content is copied into a container
+	onCopy func()
+}
+
+// testBundleDigest identifies test bundles, as the digest of a bundle is computed by callers
+const testBundleDigest = "7d0deb1cf3c846d9aa3e1f6b2c4d8e0f1a2b3c4d5e6f708192a3b4c5d6e7f809"
+
+// newTestClient returns a Client backed by an in memory docker api
+func newTestClient() (*Client, *fakeAPIClient) {
+	api := &fakeAPIClient{
+		stdout: make(map[string]string),
+		stderr: make(map[string]string),
+		execs:  make(map[string]container.ExecOptions),
+		pulls:  make(map[string]string),
+
+		volumeFiles: make(map[string][]string),
+	}
+	return &Client{
+		APIClient:       api,
+		ImageRepository: simCliPrefix,
+		ctx:             context.TODO(),
+	}, api
+}
+
+func (f *fakeAPIClient) ImageBuild(_ context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	// consume the build context to ensure a valid tar was generated
+	h := sha256.New()
+	tr := tar.NewReader(io.TeeReader(buildContext, h))
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+	}
+
+	f.builds++
+	id := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	f.images = append(f.images, &image.Summary{
+		ID:       id,
+		RepoTags: options.Tags,
+		Labels:   options.Labels,
+	})
+	body := fmt.Sprintf("{\"stream\":\"Successfully built %s\"}\n", id)
+	return types.ImageBuildResponse{
+		Body: io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func (f *fakeAPIClient) ImageList(_ context.Context, options image.ListOptions) ([]image.Summary, error) {
+	var result []image.Summary
+	for _, v := range f.images {
+		if !options.Filters.MatchKVList("label", v.Labels) {
+			continue
+		}
+
+		if options.Filters.Contains("reference") && !matchReference(options.Filters, v.RepoTags) {
+			continue
+		}
+		result = append(result, *v)
+	}
+	return result, nil
+}
+
+func (f *fakeAPIClient) ImageTag(_ context.Context, source, target string) error {
+	img, _ := f.findImage(source)
+	if img == nil {
+		return errdefs.NotFound(fmt.Errorf("no such image: %s", source))
+	}
+	img.RepoTags = append(img.RepoTags, target)
+	return nil
+}
+
+func (f *fakeAPIClient) ImageRemove(_ context.Context, ref string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	img, idx := f.findImage(ref)
+	if img == nil {
+		return nil, errdefs.NotFound(fmt.Errorf("no such image: %s", ref))
+	}
+
+	if ref != img.ID {
+		img.RepoTags = removeString(img.RepoTags, ref)
+		if len(img.RepoTags) > 0 {
+			return []image.DeleteResponse{{Untagged: ref}}, nil
+		}
+	} else if len(img.RepoTags) > 1 && !options.Force {
+		return nil, errdefs.Conflict(fmt.Errorf("unable to delete %s, image is referenced in multiple repositories", ref))
+	}
+
+	f.images = append(f.images[:idx], f.images[idx+1:]...)
+	return []image.DeleteResponse{{Deleted: img.ID}}, nil
+}
+
+// ImagePull replaces any image tagged as ref with a new image, and fails for references containing missing
+func (f *fakeAPIClient) ImagePull(_ context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
+	f.pulls[ref] = options.RegistryAuth
+	if strings.Contains(ref, "missing") {
+		body := fmt.Sprintf("{\"errorDetail\":{\"message\":\"manifest for %[1]s not found\"},\"error\":\"manifest for %[1]s not found\"}\n", ref)
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+
+	if img, _ := f.findImage(ref); img != nil {
+		img.RepoTags = removeString(img.RepoTags, ref)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d", ref, len(f.images))
+	f.images = append(f.images, &image.Summary{
+		ID:       "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		RepoTags: []string{ref},
+	})
+	body := `{"status":"Pulling fs layer","progressDetail":{},"id":"layer"}
+{"status":"Downloading","progressDetail":{"current":1,"total":2},"progress":"[====>    ]","id":"layer"}
+{"status":"Pull complete","progressDetail":{},"id":"layer"}
+`
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+// ImageSave writes the IDs and tags of the images as a tar archive
+func (f *fakeAPIClient) ImageSave(_ context.Context, refs []string) (io.ReadCloser, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, ref := range refs {
+		img, _ := f.findImage(ref)
+		if img == nil {
+			return nil, errdefs.NotFound(fmt.Errorf("no such image: %s", ref))
+		}
+		contents := strings.Join(append([]string{img.ID}, img.RepoTags...), "\n")
+		tw.WriteHeader(&tar.Header{Name: strings.TrimPrefix(img.ID, "sha256:"), Mode: 0644, Size: int64(len(contents))})
+		tw.Write([]byte(contents))
+	}
+	tw.Close()
+	return io.NopCloser(buf), nil
+}
+
+// DiskUsage reports the volumes recorded in the fake api
+func (f *fakeAPIClient) DiskUsage(_ context.Context, _ types.DiskUsageOptions) (types.DiskUsage, error) {
+	return types.DiskUsage{Volumes: f.volumes}, nil
+}
+
+// ImageLoad imports images from an archive generated by ImageSave
+func (f *fakeAPIClient) ImageLoad(_ context.Context, input io.Reader, _ bool) (image.LoadResponse, error) {
+	var body strings.Builder
+	tr := tar.NewReader(input)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return image.LoadResponse{}, err
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return image.LoadResponse{}, err
+		}
+		fields := strings.Split(string(contents), "\n")
+		f.images = append(f.images, &image.Summary{ID: fields[0], RepoTags: fields[1:]})
+		fmt.Fprintf(&body, "{\"stream\":\"Loaded image: %s\\n\"}\n", fields[1])
+	}
+	return image.LoadResponse{Body: io.NopCloser(strings.NewReader(body.String())), JSON: true}, nil
+}
+
+func (f *fakeAPIClient) ImageInspectWithRaw(_ context.Context, ref string) (types.ImageInspect, []byte, error) {
+	img, _ := f.findImage(ref)
+	if img == nil {
+		return types.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("no such image: %s", ref))
+	}
+	return types.ImageInspect{ID: img.ID, RepoTags: img.RepoTags, Size: img.Size}, nil, nil
+}
+
+// findImage returns image matching ID or tag ref along with its index
+func (f *fakeAPIClient) findImage(ref string) (*image.Summary, int) {
+	for i, v := range f.images {
+		if v.ID == ref {
+			return v, i
+		}
+		for _, tag := range v.RepoTags {
+			if tag == ref {
+				return v, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// matchReference checks if any of the tags match the reference filters
+func matchReference(args filters.Args, tags []string) bool {
+	for _, pattern := range args.Get("reference") {
+		for _, tag := range tags {
+			if ok, _ := path.Match(pattern, tag); ok {
+				return true
+			}
+			// patterns without a tag also match the repository name, like the docker reference filter
+			if i := strings.LastIndex(tag, ":"); i > strings.LastIndex(tag, "/") {
+				if ok, _ := path.Match(pattern, tag[:i]); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (f *fakeAPIClient) ContainerList(_ context.Context, options container.ListOptions) ([]types.Container, error) {
+	var result []types.Container
+	for _, v := range f.containers {
+		if !options.All && v.State != "running" {
+			continue
+		}
+
+		if !options.Filters.MatchKVList("label", v.Labels) {
+			continue
+		}
+
+		if options.Filters.Contains("name") && !matchName(options.Filters, v.Names) {
+			continue
+		}
+		result = append(result, *v)
+	}
+	return result, nil
+}
+
+func (f *fakeAPIClient) ContainerInspect(_ context.Context, id string) (types.ContainerJSON, error) {
+	for _, v := range f.containers {
+		if v.ID == id {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					ID:    v.ID,
+					Name:  v.Names[0],
+					State: &types.ContainerState{Status: v.State, Running: v.State == "running"},
+				},
+				Config: &container.Config{Labels: v.Labels},
+			}, nil
+		}
+	}
+	return types.ContainerJSON{}, errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+}
+
+func (f *fakeAPIClient) ContainerStop(_ context.Context, id string, _ container.StopOptions) error {
+	return f.setContainerState(id, "exited")
+}
+
+func (f *fakeAPIClient) ContainerStart(_ context.Context, id string, _ container.StartOptions) error {
+	return f.setContainerState(id, "running")
+}
+
+func (f *fakeAPIClient) ContainerRemove(ctx context.Context, id string, _ container.RemoveOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for i, v := range f.containers {
+		if v.ID == id {
+			f.containers = append(f.containers[:i], f.containers[i+1:]...)
+			return nil
+		}
+	}
+	return errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+}
+
+func (f *fakeAPIClient) setContainerState(id, state string) error {
+	for _, v := range f.containers {
+		if v.ID == id {
+			v.State = state
+			return nil
+		}
+	}
+	return errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+}
+
+func (f *fakeAPIClient) ContainerLogs(_ context.Context, id string, options container.LogsOptions) (io.ReadCloser, error) {
+	buf := new(bytes.Buffer)
+	if options.ShowStdout {
+		stdcopy.NewStdWriter(buf, stdcopy.Stdout).Write([]byte(f.stdout[id]))
+	}
+	if options.ShowStderr {
+		stdcopy.NewStdWriter(buf, stdcopy.Stderr).Write([]byte(f.stderr[id]))
+	}
+	return io.NopCloser(buf), nil
+}
+
+func (f *fakeAPIClient) ContainerExecCreate(_ context.Context, id string, options container.ExecOptions) (types.IDResponse, error) {
+	execID := fmt.Sprintf("%s-exec-%d", id, len(f.execs))
+	f.execs[execID] = options
+	return types.IDResponse{ID: execID}, nil
+}
+
+// ContainerExecAttach writes the command run by the exec to stdout
+func (f *fakeAPIClient) ContainerExecAttach(_ context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error) {
+	output := strings.Join(f.execs[execID].Cmd, " ") + "\n"
+	buf := new(bytes.Buffer)
+	// output of commands with a tty is not multiplexed
+	if options.Tty {
+		buf.WriteString(output)
+	} else {
+		stdcopy.NewStdWriter(buf, stdcopy.Stdout).Write([]byte(output))
+	}
+	conn, _ := net.Pipe()
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(buf)}, nil
+}
+
+func (f *fakeAPIClient) ContainerExecInspect(_ context.Context, execID string) (container.ExecInspect, error) {
+	return container.ExecInspect{ExecID: execID, ExitCode: f.execExitCode}, nil
+}
+
+// addContainer records a sim-cli managed container for instanceName
+func (f *fakeAPIClient) addContainer(id, instanceName, state string) {
+	f.containers = append(f.containers, &types.Container{
+		ID:     id,
+		Names:  []string{"/" + instanceName},
+		State:  state,
+		Labels: map[string]string{simCliPrefix: instanceName},
+	})
+}
+
+// matchName mimics the docker name filter, which matches substrings of container names
+func matchName(args filters.Args, names []string) bool {
+	for _, filter := range args.Get("name") {
+		for _, name := range names {
+			if strings.Contains(name, filter) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *fakeAPIClient) VolumeInspect(_ context.Context, name string) (volume.Volume, error) {
+	for _, v := range f.volumes {
+		if v.Name == name {
+			return *v, nil
+		}
+	}
+	return volume.Volume{}, errdefs.NotFound(fmt.Errorf("no such volume: %s", name))
+}
+
+func (f *fakeAPIClient) VolumeCreate(_ context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	v := &volume.Volume{Name: options.Name, Driver: options.Driver, Labels: options.Labels}
+	f.volumes = append(f.volumes, v)
+	return *v, nil
+}
+
+// VolumeRemove removes the volume and its files, and fails once ctx is cancelled like the docker client
+func (f *fakeAPIClient) VolumeRemove(ctx context.Context, name string, _ bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for i, v := range f.volumes {
+		if v.Name == name {
+			f.volumes = append(f.volumes[:i], f.volumes[i+1:]...)
+			delete(f.volumeFiles, name)
+			return nil
+		}
+	}
+	return errdefs.NotFound(fmt.Errorf("no such volume: %s", name))
+}
+
+// ContainerCreate records a created container with the volumes mounted in hostConfig
+func (f *fakeAPIClient) ContainerCreate(_ context.Context, config *container.Config, hostConfig *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, name string) (container.CreateResponse, error) {
+	id := fmt.Sprintf("container-%d", len(f.containers)+1)
+	c := &types.Container{
+		ID:     id,
+		Names:  []string{"/" + name},
+		Image:  config.Image,
+		State:  "created",
+		Labels: config.Labels,
+	}
+	for _, m := range hostConfig.Mounts {
+		c.Mounts = append(c.Mounts, types.MountPoint{Type: m.Type, Name: m.Source, Destination: m.Target})
+	}
+	f.containers = append(f.containers, c)
+	return container.CreateResponse{ID: id}, nil
+}
+
+// CopyToContainer records the files in the tar archive content copied into volumes mounted in the container
+func (f *fakeAPIClient) CopyToContainer(ctx context.Context, id, dstPath string, content io.Reader, _ container.CopyToContainerOptions) error {
+	if f.onCopy != nil {
+		f.onCopy()
+	}
+	c := f.findContainer(id)
+	if c == nil {
+		return errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+	}
+
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := path.Join(dstPath, hdr.Name)
+		for _, m := range c.Mounts {
+			if strings.HasPrefix(name, m.Destination+"/") {
+				f.volumeFiles[m.Name] = append(f.volumeFiles[m.Name], strings.TrimPrefix(name, m.Destination+"/"))
+			}
+		}
+	}
+}
+
+// ContainerStatPath reports files copied into volumes mounted in the container
+func (f *fakeAPIClient) ContainerStatPath(_ context.Context, id, p string) (container.PathStat, error) {
+	c := f.findContainer(id)
+	if c == nil {
+		return container.PathStat{}, errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+	}
+	for _, m := range c.Mounts {
+		for _, name := range f.volumeFiles[m.Name] {
+			if path.Join(m.Destination, name) == p {
+				return container.PathStat{Name: path.Base(p)}, nil
+			}
+		}
+	}
+	return container.PathStat{}, errdefs.NotFound(fmt.Errorf("no such file: %s", p))
+}
+
+func (f *fakeAPIClient) findContainer(id string) *types.Container {
+	for _, v := range f.containers {
+		if v.ID == id {
+			return v
+		}
+	}
+	return nil
+}
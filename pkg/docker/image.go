@@ -2,67 +2,143 @@ package docker
 
 import (
 	"bufio"
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/moby/term"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	simCliPrefix = "sim-cli-managed"
+	simCliPrefix   = "sim-cli-managed"
+	contentHashKey = simCliPrefix + "/content-hash"
 )
 
 // CreateImage will build a new image using the predefined support-bundle-kit baseImage and layer it with the actual
-// support bundle in /bundle directory. This can subsequently be loaded into the simulator
-func (c *Client) CreateImage(instanceName string, bundlePath string, baseImage string) error {
-
-	imageName := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
-	contextTar, err := BuildContextTar(bundlePath, baseImage)
+// support bundle in /bundle directory. This can subsequently be loaded into the simulator.
+// Images are labelled with a hash of the bundle and base image ID, and if an image with a matching hash already exists
+// it is tagged for the instance instead of building a new image
+func (c *Client) CreateImage(instanceName string, bundlePath string, bundleDigest string, baseImage string, labels map[string]string) error {
+
+	imageName := c.imageName(instanceName)
+	// base image ID ensures images are rebuilt when a newer base image is pulled for the same tag
+	hash := ContentHash(bundleDigest, c.imageID(baseImage))
+	existing, err := c.findImageByHash(hash)
 	if err != nil {
 		return err
 	}
 
-	imageBuildResponse, err := c.APIClient.ImageBuild(c.ctx, bytes.NewReader(contextTar.Bytes()), types.ImageBuildOptions{
-		Tags: []string{imageName},
-		Labels: map[string]string{
-			bundleNameKey: instanceName,
-		},
+	if existing != nil {
+		logrus.Infof("reusing image %s built from the same bundle and base image for instance %s", existing.ID, instanceName)
+		if err := c.APIClient.ImageTag(c.ctx, existing.ID, imageName); err != nil {
+			return fmt.Errorf("error tagging image %s as %s: %w", existing.ID, imageName, err)
+		}
+		return nil
+	}
+
+	contextTar := BuildContextTar(c.ctx, bundlePath, baseImage)
+	defer contextTar.Close()
+
+	imageLabels := make(map[string]string, len(labels)+2)
+	for k, v := range labels {
+		imageLabels[k] = v
+	}
+	imageLabels[runtime.BundleNameLabel] = instanceName
+	imageLabels[contentHashKey] = hash
+
+	imageBuildResponse, err := c.APIClient.ImageBuild(c.ctx, contextTar, types.ImageBuildOptions{
+		Tags:   []string{imageName},
+		Labels: imageLabels,
 	})
 
 	if err != nil {
 		return err
 	}
 
-	return readResponse(imageBuildResponse.Body)
+	return displayProgress(imageBuildResponse.Body)
 }
 
 // FindImage attempts to find image for a given instanceName by filtering on labels added
 // to image during the image generation process
 func (c *Client) FindImages(instanceName string) ([]image.Summary, error) {
-	imageName := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
+	imageName := c.imageName(instanceName)
 	filters := filters.NewArgs(filters.KeyValuePair{Key: "reference", Value: imageName})
 	return c.APIClient.ImageList(c.ctx, image.ListOptions{
 		Filters: filters,
 	})
 }
 
-// RemoveImages removes images associated with instanceName
+// FindAllSimManagedImages returns all images in the sim-cli image repository, along with the instances they are tagged for
+func (c *Client) FindAllSimManagedImages() ([]runtime.Image, error) {
+	filters := filters.NewArgs(filters.KeyValuePair{Key: "reference", Value: c.ImageRepository})
+	images, err := c.APIClient.ImageList(c.ctx, image.ListOptions{
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing images in repository %s: %w", c.ImageRepository, err)
+	}
+
+	var result []runtime.Image
+	for _, v := range images {
+		img := runtime.Image{
+			ID:   v.ID,
+			Size: v.Size,
+		}
+		for _, tag := range v.RepoTags {
+			if instance, ok := strings.CutPrefix(tag, c.ImageRepository+":"); ok {
+				img.Instances = append(img.Instances, instance)
+			}
+		}
+		result = append(result, img)
+	}
+	return result, nil
+}
+
+// findImageByHash returns the sim-cli managed image labelled with the content hash, or nil if no such image exists
+func (c *Client) findImageByHash(hash string) (*image.Summary, error) {
+	filters := filters.NewArgs(filters.KeyValuePair{Key: "label", Value: fmt.Sprintf("%s=%s", contentHashKey, hash)})
+	images, err := c.APIClient.ImageList(c.ctx, image.ListOptions{
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing images with content hash %s: %w", hash, err)
+	}
+
+	if len(images) == 0 {
+		return nil, nil
+	}
+	return &images[0], nil
+}
+
+// RemoveImages removes images associated with instanceName. Images may be shared by multiple instances loading
+// the same bundle, in which case only the tag for instanceName is removed, and the image is deleted once it is
+// no longer tagged for any instance
 func (c *Client) RemoveImages(instanceName string) error {
 	images, err := c.FindImages(instanceName)
 	if err != nil {
-		return nil
+		return fmt.Errorf("error listing images for instance %s: %w", instanceName, err)
 	}
 
 	for _, v := range images {
-		resp, err := c.APIClient.ImageRemove(c.ctx, v.ID, image.RemoveOptions{})
+		ref := v.ID
+		if users := c.instanceTags(v); users > 1 {
+			logrus.Infof("image %s is still used by %d other instances, removing tag only", v.ID, users-1)
+			ref = c.imageName(instanceName)
+		}
+
+		resp, err := c.APIClient.ImageRemove(c.ctx, ref, image.RemoveOptions{})
 		if err != nil {
-			return fmt.Errorf("error removing image %s: %v", v.ID, err)
+			return fmt.Errorf("error removing image %s: %v", ref, err)
 		}
 
 		for _, v := range resp {
@@ -73,7 +149,37 @@ func (c *Client) RemoveImages(instanceName string) error {
 	return nil
 }
 
-// readResponse attempts to tidy up response messages
+// instanceTags returns the number of instances an image is tagged for
+func (c *Client) instanceTags(img image.Summary) int {
+	var count int
+	for _, v := range img.RepoTags {
+		if strings.HasPrefix(v, c.ImageRepository+":") {
+			count++
+		}
+	}
+	return count
+}
+
+// ContentHash returns the hex encoded sha256 hash identifying the image built from the bundle with bundleDigest and baseImage
+func ContentHash(bundleDigest, baseImage string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s", bundleDigest, baseImage)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// displayProgress renders progress messages in the same way as the docker cli when stderr is a terminal,
+// and logs them otherwise
+func displayProgress(resp io.ReadCloser) error {
+	fd, isTerminal := term.GetFdInfo(os.Stderr)
+	if !isTerminal {
+		return readResponse(resp)
+	}
+
+	defer resp.Close()
+	return jsonmessage.DisplayJSONMessagesStream(resp, os.Stderr, fd, isTerminal, nil)
+}
+
+// readResponse attempts to tidy up response messages, and returns the error reported in the response if any
 func readResponse(resp io.ReadCloser) error {
 	defer resp.Close()
 	reader := bufio.NewReader(resp)
@@ -90,6 +196,7 @@ func readResponse(resp io.ReadCloser) error {
 
 		if msg.Error != nil {
 			logrus.Error(msg.Error)
+			return msg.Error
 		}
 
 		if msg.Aux != nil {
@@ -99,6 +206,15 @@ func readResponse(resp io.ReadCloser) error {
 		if msg.Stream != "" && msg.Stream != "\n" {
 			logrus.Info(msg.Stream)
 		}
+
+		// progress updates are skipped to avoid flooding the logs
+		if msg.Status != "" && msg.Progress == nil {
+			if msg.ID != "" {
+				logrus.Infof("%s: %s", msg.ID, msg.Status)
+			} else {
+				logrus.Info(msg.Status)
+			}
+		}
 	}
 	return nil
 }
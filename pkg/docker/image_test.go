@@ -2,16 +2,18 @@ package docker
 
 import (
 	"context"
+	"strings"
 	"testing"
 
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_ImageLifeCycle(t *testing.T) {
 	assert := require.New(t)
-	client, err := NewClient(context.TODO())
+	client, err := NewClient(context.TODO(), ClientOptions{})
 	assert.NoError(err)
-	err = client.CreateImage("dev", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", "rancher/support-bundle-kit:master-head")
+	err = client.CreateImage("dev", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", testBundleDigest, "rancher/support-bundle-kit:master-head", nil)
 	assert.NoError(err)
 	images, err := client.FindImages("dev")
 	assert.NoError(err)
@@ -19,3 +21,69 @@ func Test_ImageLifeCycle(t *testing.T) {
 	err = client.RemoveImages("dev")
 	assert.NoError(err)
 }
+
+func Test_ImageReuse(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+
+	assert.NoError(client.CreateImage("issue-7007", bundlePath, testBundleDigest, "rancher/support-bundle-kit:master-head", nil))
+	assert.NoError(client.CreateImage("issue-7007-retry", bundlePath, testBundleDigest, "rancher/support-bundle-kit:master-head", nil))
+	assert.Equal(1, api.builds, "expected image to be reused for same bundle")
+	assert.Len(api.images, 1)
+	assert.ElementsMatch([]string{"sim-cli-managed:issue-7007", "sim-cli-managed:issue-7007-retry"}, api.images[0].RepoTags)
+
+	assert.NoError(client.CreateImage("issue-7007-new-base", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", nil))
+	assert.Equal(2, api.builds, "expected new image for different base image")
+
+	updatedBundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "updated metadata",
+	})
+	assert.NoError(client.CreateImage("issue-7007-new-bundle", updatedBundlePath, strings.Repeat("0", 64), "rancher/support-bundle-kit:dev", nil))
+	assert.Equal(3, api.builds, "expected new image for different bundle digest")
+	assert.NoError(client.RemoveImages("issue-7007-new-bundle"))
+
+	assert.NoError(client.RemoveImages("issue-7007"))
+	images, err := client.FindImages("issue-7007-retry")
+	assert.NoError(err)
+	assert.Len(images, 1, "expected image to be retained while used by other instances")
+	assert.Equal([]string{"sim-cli-managed:issue-7007-retry"}, images[0].RepoTags)
+
+	assert.NoError(client.RemoveImages("issue-7007-retry"))
+	assert.NoError(client.RemoveImages("issue-7007-new-base"))
+	assert.Empty(api.images, "expected all images to be removed")
+}
+
+func Test_FindAllSimManagedImages(t *testing.T) {
+	assert := require.New(t)
+	client, _ := newTestClient()
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+	assert.NoError(client.PullImage("rancher/support-bundle-kit:dev"))
+	assert.NoError(client.CreateImage("issue-7007", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", nil))
+	assert.NoError(client.CreateImage("issue-7007-retry", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", nil))
+
+	images, err := client.FindAllSimManagedImages()
+	assert.NoError(err)
+	assert.Len(images, 1, "expected base image to be excluded")
+	assert.ElementsMatch([]string{"issue-7007", "issue-7007-retry"}, images[0].Instances)
+}
+
+func Test_CreateImageLabels(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+
+	labels := runtime.Metadata{BundleSHA256: "7d0deb1cf3", Version: "v0.1.0"}.Labels()
+	assert.NoError(client.CreateImage("issue-7007", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", labels))
+	assert.Len(api.images, 1)
+	assert.Equal("issue-7007", api.images[0].Labels[runtime.BundleNameLabel])
+	assert.Equal("7d0deb1cf3", api.images[0].Labels[runtime.BundleSHA256Label])
+	assert.Equal("v0.1.0", api.images[0].Labels[runtime.VersionLabel])
+	assert.NotEmpty(api.images[0].Labels[contentHashKey])
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+)
+
+// TailLogs returns the last lines of stdout and stderr from the container associated with instanceName
+func (c *Client) TailLogs(instanceName string, lines int) (string, error) {
+	buf := new(bytes.Buffer)
+	err := c.StreamLogs(instanceName, runtime.LogOptions{Tail: fmt.Sprintf("%d", lines)}, buf, buf)
+	return buf.String(), err
+}
+
+// StreamLogs writes the stdout and stderr logs of the container associated with instanceName to stdout and stderr.
+// Logs are available for running and exited containers, and when following logs StreamLogs returns once the
+// container stops
+func (c *Client) StreamLogs(instanceName string, opts runtime.LogOptions, stdout, stderr io.Writer) error {
+	containers, err := c.FindContainer(instanceName)
+	if err != nil {
+		return fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
+	}
+
+	if len(containers) != 1 {
+		return fmt.Errorf("expected one container for instance %s, got %d", instanceName, len(containers))
+	}
+
+	info, err := c.APIClient.ContainerInspect(c.ctx, containers[0].ID)
+	if err != nil {
+		return fmt.Errorf("error inspecting container %s: %w", instanceName, err)
+	}
+
+	logs, err := c.APIClient.ContainerLogs(c.ctx, containers[0].ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Since:      opts.Since,
+		Tail:       opts.Tail,
+		Timestamps: opts.Timestamps,
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching logs for container %s: %w", instanceName, err)
+	}
+	defer logs.Close()
+
+	// logs of containers with a tty are not multiplexed
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(stdout, logs)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, logs)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading logs for container %s: %w", instanceName, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StreamLogs(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	api.addContainer("exited-id", "issue-7007", "exited")
+	api.stdout["exited-id"] = "loading bundle\n"
+	api.stderr["exited-id"] = "simulator failed\n"
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	assert.NoError(client.StreamLogs("issue-7007", runtime.LogOptions{}, stdout, stderr))
+	assert.Equal("loading bundle\n", stdout.String())
+	assert.Equal("simulator failed\n", stderr.String())
+
+	logs, err := client.TailLogs("issue-7007", 10)
+	assert.NoError(err)
+	assert.Equal("loading bundle\nsimulator failed\n", logs)
+
+	assert.Error(client.StreamLogs("issue-113", runtime.LogOptions{}, stdout, stderr), "expected error for missing instance")
+}
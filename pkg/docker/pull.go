@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+)
+
+// PullImage pulls ref from its registry, using the registry credentials from the docker cli config
+func (c *Client) PullImage(ref string) error {
+	var auth string
+	if c.ConfigFile != nil {
+		var err error
+		auth, err = command.RetrieveAuthTokenFromImage(c.ConfigFile, ref)
+		if err != nil {
+			return fmt.Errorf("error fetching registry credentials for image %s: %w", ref, err)
+		}
+	}
+
+	logrus.Infof("pulling image %s", ref)
+	resp, err := c.APIClient.ImagePull(c.ctx, ref, image.PullOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return fmt.Errorf("error pulling image %s: %w", ref, err)
+	}
+
+	if err := displayProgress(resp); err != nil {
+		return fmt.Errorf("error pulling image %s: %w", ref, err)
+	}
+	return nil
+}
+
+// ImageExists checks if ref is present in the local image store
+func (c *Client) ImageExists(ref string) (bool, error) {
+	_, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, ref)
+	if err == nil {
+		return true, nil
+	}
+
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("error inspecting image %s: %w", ref, err)
+}
+
+// ImageDigest returns the repository digest of ref, or its ID if the image was built or loaded locally
+func (c *Client) ImageDigest(ref string) (string, error) {
+	info, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("error inspecting image %s: %w", ref, err)
+	}
+
+	if len(info.RepoDigests) != 0 {
+		return info.RepoDigests[0], nil
+	}
+	return info.ID, nil
+}
+
+// InspectImage returns the details of ref, including its size and layers
+func (c *Client) InspectImage(ref string) (types.ImageInspect, error) {
+	info, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, ref)
+	if err != nil {
+		return types.ImageInspect{}, fmt.Errorf("error inspecting image %s: %w", ref, err)
+	}
+	return info, nil
+}
+
+// imageID returns the ID of ref, or ref itself if the image is not present locally
+func (c *Client) imageID(ref string) string {
+	info, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, ref)
+	if err != nil {
+		logrus.Debugf("unable to inspect image %s: %v", ref, err)
+		return ref
+	}
+	return info.ID
+}
+
+// SaveImage writes ref and its layers as a tar archive to w, which can be imported with LoadImage
+func (c *Client) SaveImage(ref string, w io.Writer) error {
+	resp, err := c.APIClient.ImageSave(c.ctx, []string{ref})
+	if err != nil {
+		return fmt.Errorf("error saving image %s: %w", ref, err)
+	}
+	defer resp.Close()
+
+	if _, err := io.Copy(w, resp); err != nil {
+		return fmt.Errorf("error writing image %s: %w", ref, err)
+	}
+	return nil
+}
+
+// LoadImage imports images from a tar archive generated by SaveImage or docker save
+func (c *Client) LoadImage(r io.Reader) error {
+	resp, err := c.APIClient.ImageLoad(c.ctx, r, false)
+	if err != nil {
+		return fmt.Errorf("error loading image: %w", err)
+	}
+
+	if !resp.JSON {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("error reading image load response: %w", err)
+		}
+		logrus.Info(string(body))
+		return nil
+	}
+
+	if err := displayProgress(resp.Body); err != nil {
+		return fmt.Errorf("error loading image: %w", err)
+	}
+	return nil
+}
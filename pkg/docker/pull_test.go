@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PullImage(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	client.ConfigFile = configfile.New("")
+	client.ConfigFile.AuthConfigs["registry.example.com"] = types.AuthConfig{
+		ServerAddress: "registry.example.com",
+		Username:      "support",
+		Password:      "secret",
+	}
+
+	exists, err := client.ImageExists("registry.example.com/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.False(exists)
+
+	assert.NoError(client.PullImage("registry.example.com/support-bundle-kit:dev"))
+	exists, err = client.ImageExists("registry.example.com/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.True(exists)
+
+	auth, err := base64.URLEncoding.DecodeString(api.pulls["registry.example.com/support-bundle-kit:dev"])
+	assert.NoError(err)
+	credentials := make(map[string]string)
+	assert.NoError(json.Unmarshal(auth, &credentials))
+	assert.Equal("support", credentials["username"])
+	assert.Equal("secret", credentials["password"])
+
+	assert.ErrorContains(client.PullImage("rancher/support-bundle-kit:missing"), "manifest for rancher/support-bundle-kit:missing not found")
+}
+
+func Test_ImageRebuiltForNewBaseImage(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+
+	assert.NoError(client.PullImage("rancher/support-bundle-kit:dev"))
+	assert.NoError(client.CreateImage("issue-7007", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", nil))
+	assert.NoError(client.CreateImage("issue-7007-retry", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", nil))
+	assert.Equal(1, api.builds, "expected image to be reused for same base image")
+
+	assert.NoError(client.PullImage("rancher/support-bundle-kit:dev"))
+	assert.NoError(client.CreateImage("issue-7007-updated", bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev", nil))
+	assert.Equal(2, api.builds, "expected new image after pulling newer base image")
+}
+
+func Test_SaveLoadImage(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	assert.NoError(client.PullImage("rancher/support-bundle-kit:dev"))
+
+	archive := new(bytes.Buffer)
+	assert.NoError(client.SaveImage("rancher/support-bundle-kit:dev", archive))
+	assert.Error(client.SaveImage("rancher/support-bundle-kit:missing", new(bytes.Buffer)))
+
+	api.images = nil
+	assert.NoError(client.LoadImage(archive))
+	exists, err := client.ImageExists("rancher/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.True(exists, "expected image to be loaded")
+}
+
+func Test_InspectImage(t *testing.T) {
+	assert := require.New(t)
+	client, _ := newTestClient()
+	assert.NoError(client.PullImage("rancher/support-bundle-kit:dev"))
+
+	info, err := client.InspectImage("rancher/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.NotEmpty(info.ID)
+	assert.Contains(info.RepoTags, "rancher/support-bundle-kit:dev")
+
+	_, err = client.InspectImage("rancher/support-bundle-kit:missing")
+	assert.ErrorContains(err, "error inspecting image")
+}
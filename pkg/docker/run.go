@@ -5,39 +5,90 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
+	"strconv"
 
-	"github.com/bndr/gotabulate"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/go-connections/nat"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/sirupsen/logrus"
 )
 
-// RunContainer runs an instance of support-bundle-kit simulator in a docker container image
-func (c *Client) RunContainer(instanceName, bundlePath string) error {
-	imageName := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
+const (
+	defaultBindAddress = "127.0.0.1"
+)
+
+// RunContainer runs an instance of support-bundle-kit simulator in a docker container image. When a bundle volume
+// is specified it is mounted into the container instead of using the bundle packaged in the instance image
+func (c *Client) RunContainer(opts runtime.RunOptions) error {
+	instanceName := opts.InstanceName
+	imageName := opts.Image
+	if imageName == "" {
+		imageName = c.imageName(instanceName)
+	}
+
+	bindAddress := opts.BindAddress
+	if bindAddress == "" {
+		bindAddress = defaultBindAddress
+	}
+
+	labels := opts.Metadata.Labels()
+	labels[runtime.BundleNameLabel] = opts.BundlePath
+	labels[runtime.InstanceLabel] = instanceName
+	labels[runtime.BindAddressLabel] = bindAddress
+
+	var hostPort string
+	if opts.HostPort != 0 {
+		hostPort = strconv.Itoa(opts.HostPort)
+		labels[runtime.HostPortLabel] = hostPort
+	}
+
+	if opts.NanoCPUs != 0 {
+		labels[runtime.CPUsLabel] = strconv.FormatInt(opts.NanoCPUs, 10)
+	}
+	if opts.Memory != 0 {
+		labels[runtime.MemoryLabel] = strconv.FormatInt(opts.Memory, 10)
+	}
+
+	var mounts []mount.Mount
+	if opts.Volume != "" {
+		labels[runtime.BundleVolumeLabel] = opts.Volume
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   opts.Volume,
+			Target:   bundleMountPath,
+			ReadOnly: true,
+		})
+	}
+
 	resp, err := c.APIClient.ContainerCreate(c.ctx, &container.Config{
 		Image: imageName,
-		Cmd:   []string{"support-bundle-kit", "simulator", "reset", "--bundle-path", "/bundle"},
+		Cmd:   []string{"support-bundle-kit", "simulator", "reset", "--bundle-path", bundleMountPath},
 		ExposedPorts: map[nat.Port]struct{}{
 			"6443/tcp": struct{}{},
 		},
-		Tty: false,
-		Labels: map[string]string{
-			bundleNameKey: bundlePath,
-			simCliPrefix:  instanceName,
-		},
+		Tty:    false,
+		Labels: labels,
 	}, &container.HostConfig{
-		AutoRemove:  true,
 		NetworkMode: "bridge",
 		PortBindings: map[nat.Port][]nat.PortBinding{
 			"6443/tcp": {
 				{
-					HostIP: "0.0.0.0",
+					HostIP:   bindAddress,
+					HostPort: hostPort,
 				},
 			},
 		},
+		Mounts: mounts,
+		Resources: container.Resources{
+			NanoCPUs: opts.NanoCPUs,
+			Memory:   opts.Memory,
+		},
 	},
 		nil, nil, instanceName)
 	if err != nil {
@@ -53,105 +104,171 @@ func (c *Client) RunContainer(instanceName, bundlePath string) error {
 
 // FindRunningContainer attempts to find instance of simulator associated with the instanceName
 func (c *Client) FindRunningContainer(instanceName string) ([]types.Container, error) {
-	filters := filters.NewArgs(filters.KeyValuePair{Key: "name", Value: instanceName})
 	return c.APIClient.ContainerList(c.ctx, container.ListOptions{
-		Filters: filters,
+		Filters: instanceFilter(instanceName),
 	})
 
 }
 
-// StopContainer attempts to find and stop a running instance of a container associated with given instanceName
+// FindContainer attempts to find instance of simulator associated with the instanceName, including stopped containers
+func (c *Client) FindContainer(instanceName string) ([]types.Container, error) {
+	return c.APIClient.ContainerList(c.ctx, container.ListOptions{
+		Filters: instanceFilter(instanceName),
+		All:     true,
+	})
+}
+
+// instanceFilter matches the containers of instanceName by the instance label. Unlike the name filter, which matches
+// substrings of container names, label filters match exactly so instances with overlapping names are not confused
+func instanceFilter(instanceName string) filters.Args {
+	return filters.NewArgs(filters.KeyValuePair{Key: "label", Value: fmt.Sprintf("%s=%s", runtime.InstanceLabel, instanceName)})
+}
+
+// InspectContainer returns details of the container associated with instanceName, including stopped containers
+func (c *Client) InspectContainer(instanceName string) (types.ContainerJSON, error) {
+	containers, err := c.FindContainer(instanceName)
+	if err != nil {
+		return types.ContainerJSON{}, fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
+	}
+
+	if len(containers) != 1 {
+		return types.ContainerJSON{}, fmt.Errorf("expected one container for instance %s, got %d", instanceName, len(containers))
+	}
+
+	return c.APIClient.ContainerInspect(c.ctx, containers[0].ID)
+}
+
+// StopContainer attempts to find and stop a running instance of a container associated with given instanceName.
+// The container is retained and can be started again
 func (c *Client) StopContainer(instanceName string) error {
 	containers, err := c.FindRunningContainer(instanceName)
 	if err != nil {
-		return fmt.Errorf("error listing containers matching name %s: %w", instanceName, err)
+		return fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
 	}
 
 	for _, v := range containers {
-		if err := c.APIClient.ContainerStop(c.ctx, v.ID, container.StopOptions{Signal: "SIGKILL"}); err != nil {
+		if err := c.APIClient.ContainerStop(c.ctx, v.ID, container.StopOptions{}); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// StartContainer attempts to find and start a stopped container associated with given instanceName
+func (c *Client) StartContainer(instanceName string) error {
+	containers, err := c.FindContainer(instanceName)
+	if err != nil {
+		return fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
+	}
+
+	for _, v := range containers {
+		if err := c.APIClient.ContainerStart(c.ctx, v.ID, container.StartOptions{}); err != nil {
+			return fmt.Errorf("error starting container %s: %w", instanceName, err)
+		}
+	}
+	return nil
+}
+
+// RemoveContainer attempts to find and remove containers associated with given instanceName, stopping them if needed
+func (c *Client) RemoveContainer(instanceName string) error {
+	containers, err := c.FindContainer(instanceName)
+	if err != nil {
+		return fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
+	}
+
+	for _, v := range containers {
+		if err := c.APIClient.ContainerRemove(c.ctx, v.ID, container.RemoveOptions{Force: true}); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("error removing container %s: %w", v.ID, err)
+		}
+	}
+	return nil
+}
+
 // QueryExposedMapping attempts to find details of host/port needed for configuring the kubeconfig needed
 // to access the instance running in associated container
 func (c *Client) QueryExposedMapping(instanceName string) (string, string, error) {
 	var endpoint, port string
 	containers, err := c.FindRunningContainer(instanceName)
 	if err != nil {
-		return endpoint, port, fmt.Errorf("error listing containers matching name %s: %w", instanceName, err)
+		return endpoint, port, fmt.Errorf("error listing containers for instance %s: %w", instanceName, err)
 	}
 
 	if len(containers) != 1 {
-		return endpoint, port, fmt.Errorf("expected one container matching name %s, got %d", instanceName, len(containers))
+		return endpoint, port, fmt.Errorf("expected one container for instance %s, got %d", instanceName, len(containers))
+	}
+
+	mapping, ok := runtime.PublishedPort(containers[0].Ports)
+	if !ok {
+		return endpoint, port, fmt.Errorf("instance %s is in state %s: %w", instanceName, runtime.ContainerState(containers[0]), runtime.ErrNoPublishedPort)
 	}
+	port = fmt.Sprintf("%d", mapping.PublicPort)
+	endpoint, err = exposedEndpoint(c.Endpoint.Host, mapping)
+	return endpoint, port, err
+}
 
-	port = fmt.Sprintf("%d", containers[0].Ports[0].PublicPort)
-	netconfig, err := url.Parse(c.Endpoint.Host)
+// exposedEndpoint returns the address the port mapping is reachable on from the host running sim-cli.
+// Ports published by a local daemon, over a unix socket or named pipe, are reachable on the address they are
+// bound to. Ports published by a remote daemon, over tcp or ssh, are reachable on the daemon host
+func exposedEndpoint(host string, mapping types.Port) (string, error) {
+	netconfig, err := url.Parse(host)
 	if err != nil {
-		return endpoint, port, fmt.Errorf("error parsing endpoint info: %w", err)
+		return "", fmt.Errorf("error parsing endpoint info: %w", err)
 	}
-	endpoint = netconfig.Host
-	// when using local docker sock, this will be an empty string
-	if endpoint == "" {
-		endpoint = "localhost"
+
+	ip := net.ParseIP(mapping.IP)
+	switch netconfig.Scheme {
+	case "tcp", "http", "https", "ssh":
+		// ports bound to a specific address on the remote host are reachable on that address
+		if ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+			return mapping.IP, nil
+		}
+		if ip != nil && ip.IsLoopback() {
+			logrus.Warnf("port %d is bound to %s on remote docker host %s and is not reachable from this host, use --bind-address 0.0.0.0 to publish it on all addresses",
+				mapping.PublicPort, mapping.IP, netconfig.Hostname())
+		}
+		// hostname excludes the user and port of ssh endpoints
+		return netconfig.Hostname(), nil
+	default:
+		// port is only reachable on the address it is bound to
+		if ip != nil && !ip.IsUnspecified() {
+			return mapping.IP, nil
+		}
+		return "localhost", nil
 	}
-	return endpoint, port, nil
 }
 
-// FindAllSimManagedInstances returns details of all sim-cli managed instances and presents them in a tabular form
-func (c *Client) FindAllSimManagedInstances() error {
+// FindAllSimManagedContainers returns all containers managed by sim-cli, including stopped containers
+func (c *Client) FindAllSimManagedContainers() ([]types.Container, error) {
 	filters := filters.NewArgs(filters.KeyValuePair{Key: "label", Value: simCliPrefix})
-	containers, err := c.APIClient.ContainerList(c.ctx, container.ListOptions{
+	return c.APIClient.ContainerList(c.ctx, container.ListOptions{
 		Filters: filters,
 		All:     true,
 	})
-	if err != nil {
-		return fmt.Errorf("error listing containers: %w", err)
-	}
-
-	generateTable(containers)
-	return nil
 }
 
-// generateTable is a helper method to return results in a tabular form
-func generateTable(containers []types.Container) {
-	var results [][]interface{}
-
-	// gotabulate does no handle empty table and panics
-	// so for now we send an empty row if there is nothing returned
-	if len(containers) == 0 {
-		results = append(results, []interface{}{"", "", "", "", ""})
+// FindAllSimManagedInstances returns all sim-cli managed instances, including stopped instances
+func (c *Client) FindAllSimManagedInstances() ([]runtime.Instance, error) {
+	containers, err := c.FindAllSimManagedContainers()
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %w", err)
 	}
 
+	instances := make([]runtime.Instance, 0, len(containers))
 	for _, v := range containers {
-		name := v.Labels[simCliPrefix]
-		bundlePath := v.Labels[bundleNameKey]
-		image := v.Image
-		status := v.Status
-		port := fmt.Sprintf("%d", v.Ports[0].PublicPort)
-		results = append(results, []interface{}{name, bundlePath, image, status, port})
-	}
-	table := gotabulate.Create(results)
-	table.SetHeaders([]string{"name", "bundlePath", "image", "status", "exposed port"})
-	table.SetEmptyString("None")
-	table.SetAlign("right")
-	table.SetMaxCellSize(40)
-	table.SetWrapStrings(true)
-	fmt.Println(table.Render("grid"))
+		instances = append(instances, runtime.NewInstance(v))
+	}
+	return instances, nil
 }
 
 // ReadFile will read a specific file from a running container and return the results
 func (c *Client) ReadFile(name string, path string) ([]byte, error) {
 	containers, err := c.FindRunningContainer(name)
 	if err != nil {
-		return nil, fmt.Errorf("error listing containers matching name %s: %w", name, err)
+		return nil, fmt.Errorf("error listing containers for instance %s: %w", name, err)
 	}
 
 	if len(containers) != 1 {
-		return nil, fmt.Errorf("expected one container matching name %s, got %d", name, len(containers))
+		return nil, fmt.Errorf("expected one container for instance %s, got %d", name, len(containers))
 	}
 	contents, _, err := c.APIClient.CopyFromContainer(c.ctx, containers[0].ID, path)
 	if err != nil {
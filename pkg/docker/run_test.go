@@ -5,16 +5,21 @@ import (
 	"os"
 	"testing"
 
+	"github.com/docker/docker/api/types"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_ContainerLifeCycle(t *testing.T) {
 	assert := require.New(t)
-	client, err := NewClient(context.TODO())
+	client, err := NewClient(context.TODO(), ClientOptions{})
 	assert.NoError(err)
-	err = client.CreateImage("issue-113", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", "rancher/support-bundle-kit:master-head")
+	err = client.CreateImage("issue-113", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", testBundleDigest, "rancher/support-bundle-kit:master-head", nil)
 	assert.NoError(err)
-	err = client.RunContainer("issue-113", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip")
+	err = client.RunContainer(runtime.RunOptions{
+		InstanceName: "issue-113",
+		BundlePath:   "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip",
+	})
 	assert.NoError(err)
 	contents, err := client.ReadFile("issue-7007", simKubeConfigPath)
 	assert.NoError(err)
@@ -25,7 +30,114 @@ func Test_ContainerLifeCycle(t *testing.T) {
 	assert.NotNil(contents, "expected content to not be nil")
 	err = client.StopContainer("issue-113")
 	assert.NoError(err)
+	err = client.StartContainer("issue-113")
+	assert.NoError(err)
+	err = client.RemoveContainer("issue-113")
+	assert.NoError(err)
 	err = client.RemoveImages("issue-113")
 	assert.NoError(err)
 	assert.NoError(os.Remove(file.Name()), "expected no error while cleaning up temp file")
 }
+
+func Test_FindAllSimManagedInstances(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	instances, err := client.FindAllSimManagedInstances()
+	assert.NoError(err)
+	assert.NotNil(instances)
+	assert.Empty(instances)
+
+	api.addContainer("running-id", "issue-7007", "running")
+	api.addContainer("exited-id", "issue-113", "exited")
+	api.containers = append(api.containers, &types.Container{ID: "other-id", Names: []string{"/other"}, State: "running"})
+	instances, err = client.FindAllSimManagedInstances()
+	assert.NoError(err)
+	assert.Len(instances, 2, "expected containers not managed by sim-cli to be ignored")
+	assert.Equal("issue-7007", instances[0].Name)
+	assert.Equal("running-id", instances[0].ContainerID)
+	assert.Equal(runtime.StateExited, instances[1].State)
+}
+
+func Test_QueryExposedMapping(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	client.Endpoint.Host = "unix:///var/run/docker.sock"
+	api.addContainer("running-id", "issue-7007", "running")
+	_, _, err := client.QueryExposedMapping("issue-7007")
+	assert.ErrorIs(err, runtime.ErrNoPublishedPort, "expected error for running container without published port")
+
+	api.containers[0].Ports = []types.Port{
+		{IP: "::", PrivatePort: 6443, PublicPort: 30000, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: 6443, PublicPort: 30000, Type: "tcp"},
+	}
+	endpoint, port, err := client.QueryExposedMapping("issue-7007")
+	assert.NoError(err)
+	assert.Equal("localhost", endpoint)
+	assert.Equal("30000", port)
+}
+
+func Test_OverlappingInstanceNames(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	api.addContainer("short-id", "issue-70", "running")
+	api.addContainer("long-id", "issue-7007", "running")
+	api.addContainer("prefix-id", "sim-issue-70", "running")
+
+	containers, err := client.FindRunningContainer("issue-70")
+	assert.NoError(err)
+	assert.Len(containers, 1, "expected only exact match for instance name")
+	assert.Equal("short-id", containers[0].ID)
+
+	info, err := client.InspectContainer("issue-70")
+	assert.NoError(err)
+	assert.Equal("short-id", info.ID)
+
+	assert.NoError(client.StopContainer("issue-70"))
+	containers, err = client.FindContainer("issue-70")
+	assert.NoError(err)
+	assert.Len(containers, 1)
+	assert.Equal("exited", containers[0].State)
+	running, err := client.FindRunningContainer("issue-7007")
+	assert.NoError(err)
+	assert.Len(running, 1, "expected instance with overlapping name to keep running")
+
+	assert.NoError(client.StartContainer("issue-70"))
+	assert.NoError(client.RemoveContainer("issue-70"))
+	assert.Len(api.containers, 2)
+	for _, name := range []string{"issue-7007", "sim-issue-70"} {
+		containers, err = client.FindContainer(name)
+		assert.NoError(err)
+		assert.Len(containers, 1, "expected instance %s to be retained", name)
+	}
+
+	containers, err = client.FindContainer("issue")
+	assert.NoError(err)
+	assert.Empty(containers, "expected no match for prefix of instance names")
+}
+
+func Test_exposedEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		ip       string
+		endpoint string
+	}{
+		{name: "unix socket bound to loopback", host: "unix:///var/run/docker.sock", ip: "127.0.0.1", endpoint: "127.0.0.1"},
+		{name: "unix socket bound to all addresses", host: "unix:///var/run/docker.sock", ip: "0.0.0.0", endpoint: "localhost"},
+		{name: "unix socket bound to ipv6 all addresses", host: "unix:///var/run/docker.sock", ip: "::", endpoint: "localhost"},
+		{name: "named pipe", host: "npipe:////./pipe/docker_engine", ip: "0.0.0.0", endpoint: "localhost"},
+		{name: "tcp bound to all addresses", host: "tcp://10.0.0.5:2376", ip: "0.0.0.0", endpoint: "10.0.0.5"},
+		{name: "tcp bound to specific address", host: "tcp://docker.example.com:2376", ip: "10.0.0.6", endpoint: "10.0.0.6"},
+		{name: "tcp bound to loopback", host: "tcp://10.0.0.5:2376", ip: "127.0.0.1", endpoint: "10.0.0.5"},
+		{name: "ssh with user and port", host: "ssh://admin@docker.example.com:2222", ip: "0.0.0.0", endpoint: "docker.example.com"},
+		{name: "ssh ipv6", host: "ssh://admin@[fd00::5]", ip: "::", endpoint: "fd00::5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, err := exposedEndpoint(tt.host, types.Port{IP: tt.ip, PublicPort: 30000, PrivatePort: 6443})
+			require.NoError(t, err)
+			require.Equal(t, tt.endpoint, endpoint)
+		})
+	}
+}
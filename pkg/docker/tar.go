@@ -2,120 +2,220 @@ package docker
 
 import (
 	"archive/tar"
-	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"io"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 const (
 	defaultBundleDir = "bundle"
 )
 
+// TarHandler generates the build context for a bundle by streaming the contents of the
+// support bundle straight into a tar archive, without extracting it to disk. Bundles can be
+// zip, tar, tar.gz or tar.zst files, or a directory containing an extracted bundle.
+// Generating the archive stops once ctx is cancelled
 type TarHandler struct {
-	TmpDirName string
+	BundlePath string
+	BaseImage  string
+	ctx        context.Context
 }
 
-func NewTarHandler() (*TarHandler, error) {
-	dirName, err := os.MkdirTemp(os.TempDir(), "sim-cli")
-	if err != nil {
-		return nil, fmt.Errorf("error creating temp dir: %v", err)
-	}
+func NewTarHandler(ctx context.Context, bundlePath, baseImage string) *TarHandler {
 	return &TarHandler{
-		TmpDirName: dirName,
-	}, nil
+		BundlePath: bundlePath,
+		BaseImage:  baseImage,
+		ctx:        ctx,
+	}
 }
 
-func (t *TarHandler) Cleanup() error {
-	return os.RemoveAll(t.TmpDirName)
+// WriteContext writes a tar archive containing the Dockerfile and the contents of the support bundle
+// in the bundle directory to w
+func (t *TarHandler) WriteContext(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	if err := t.AddDockerFile(tw); err != nil {
+		return err
+	}
+
+	return t.WriteBundle(tw)
 }
 
-// UnzipSupportBundle will unzip bundle into memory FS
-// which can then be used to generate a tar ball for providing a context
-// to build image with bundle packaged into support-bundle-kit base image
-func (t *TarHandler) UnzipSupportBundle(bundleZipFile string) (err error) {
+// WriteBundle writes the contents of the support bundle in the bundle directory to tw, and closes tw
+func (t *TarHandler) WriteBundle(tw *tar.Writer) error {
+	if err := t.AddSupportBundle(tw, defaultBundleDir); err != nil {
+		return err
+	}
 
-	// ensure destination exists
-	destination := t.TmpDirName
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing tar file %v", err)
+	}
+	return nil
+}
 
-	r, err := zip.OpenReader(bundleZipFile)
+// AddDockerFile adds the Dockerfile generated from embedded template to root of the tar archive
+func (t *TarHandler) AddDockerFile(tw *tar.Writer) error {
+	dockerFile, err := generateTemplate(t.BaseImage)
 	if err != nil {
+		return fmt.Errorf("error generating dockerfile from embedded template")
+	}
+
+	hdr := &tar.Header{
+		Name:    "Dockerfile",
+		Mode:    0644,
+		Size:    int64(dockerFile.Len()),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("error writing dockerfile header: %w", err)
+	}
+	_, err = tw.Write(dockerFile.Bytes())
+	return err
+}
+
+// AddSupportBundle copies the contents of the support bundle into the tar archive under prefix.
+// Support bundles are packaged in a top level directory named after the bundle, which is replaced with prefix
+// to ensure consistent packaging regardless of the format of the bundle
+func (t *TarHandler) AddSupportBundle(tw *tar.Writer, prefix string) error {
+	format, err := DetectBundleFormat(t.BundlePath)
+	if err != nil {
+		return err
+	}
+
+	// the bundle is walked twice to find the top level directory shared by all entries before copying,
+	// as tar archives can only be read in order
+	var entries []bundleEntry
+	if err := walkBundle(t.ctx, t.BundlePath, format, func(e bundleEntry) error {
+		entries = append(entries, bundleEntry{name: e.name, mode: e.mode})
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	for _, f := range r.File {
-		destPath := filepath.Join(destination, f.Name)
-		if !strings.HasPrefix(destPath, filepath.Clean(destination)+string(os.PathSeparator)) {
-			return fmt.Errorf("invalid dest path %s", destPath)
+	root := commonRoot(entries)
+	return walkBundle(t.ctx, t.BundlePath, format, func(e bundleEntry) error {
+		if err := t.ctx.Err(); err != nil {
+			return err
 		}
 
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(destPath, os.ModePerm); err != nil {
-				return err
-			}
-		} else {
-			if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
-				return err
-			}
-
-			destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_CREATE, f.Mode())
-			if err != nil {
-				return err
-			}
-
-			zFile, err := f.Open()
-			if err != nil {
-				return err
-			}
-
-			if _, err = io.Copy(destFile, zFile); err != nil {
-				return err
-			}
-			zFile.Close()
-			destFile.Close()
+		name, err := bundleEntryName(prefix, root, e.name)
+		if err != nil {
+			return err
 		}
 
-	}
+		// top level directory is replaced by prefix
+		if name == "" {
+			return nil
+		}
+
+		if err := addBundleEntry(t.ctx, tw, e, name); err != nil {
+			return fmt.Errorf("error adding %s to tar: %w", e.name, err)
+		}
+		return nil
+	})
+}
 
-	// rename support bundle to ensure consistent tar file packaging
-	baseFilePath := filepath.Base(bundleZipFile)
-	baseDirName := strings.Split(baseFilePath, ".zip")
-	return os.Rename(filepath.Join(destination, baseDirName[0]), filepath.Join(destination, defaultBundleDir))
+// addBundleEntry streams a single bundle entry into the tar archive as name
+func addBundleEntry(ctx context.Context, tw *tar.Writer, e bundleEntry, name string) error {
+	switch {
+	case e.mode.IsDir():
+		return tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     name + "/",
+			Mode:     int64(e.mode.Perm()),
+			ModTime:  e.modTime,
+		})
+	case e.mode.IsRegular():
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     int64(e.mode.Perm()),
+			Size:     e.size,
+			ModTime:  e.modTime,
+		}); err != nil {
+			return err
+		}
+		f, err := e.open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, &contextReader{ctx: ctx, r: f})
+		return err
+	default:
+		logrus.Warnf("skipping unsupported file %s of type %s in support bundle", e.name, e.mode.Type())
+		return nil
+	}
 }
 
-// GenerateBundleTar attempts to parse FS/bundle to build a tar which can be passed
-// as context for image creation step
-func (t *TarHandler) GenerateBundleTar() (*bytes.Buffer, error) {
-	fs := os.DirFS(t.TmpDirName)
-	buf := &bytes.Buffer{}
-	contextTar := tar.NewWriter(buf)
-	if err := contextTar.AddFS(fs); err != nil {
-		return nil, fmt.Errorf("error adding tmp directory %s to tar: %v", t.TmpDirName, err)
+// commonRoot returns the top level directory shared by all entries in the bundle, or an empty string
+// if the entries do not share a single top level directory
+func commonRoot(entries []bundleEntry) string {
+	var root string
+	for _, e := range entries {
+		first, ok := entryRoot(e)
+		if !ok {
+			continue
+		}
+		// file at the top level of the bundle
+		if first == "" {
+			return ""
+		}
+		if root != "" && root != first {
+			return ""
+		}
+		root = first
 	}
+	return root
+}
 
-	if err := contextTar.Close(); err != nil {
-		return nil, fmt.Errorf("error closing tar file %v", err)
+// entryRoot returns the top level directory containing the entry, or an empty string for files at the top
+// level of the bundle. Entries for the bundle directory itself, which are included in tar archives created
+// from within the bundle directory, do not identify a top level directory
+func entryRoot(e bundleEntry) (string, bool) {
+	cleaned := path.Clean(strings.TrimPrefix(e.name, "./"))
+	if cleaned == "." {
+		return "", false
 	}
-	return buf, nil
+	first, rest, _ := strings.Cut(cleaned, "/")
+	if rest == "" && !e.mode.IsDir() {
+		return "", true
+	}
+	return first, true
 }
 
-func (t *TarHandler) AddDockerFile(baseImage string) error {
-	dockerFile, err := generateTemplate(baseImage)
-	if err != nil {
-		return fmt.Errorf("error generating dockerfile from embedded template")
+// bundleEntryName returns the name of the bundle entry in the tar archive with root replaced by prefix
+func bundleEntryName(prefix, root, name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(name, "./"))
+	if cleaned == "." {
+		return "", nil
+	}
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid dest path %s", name)
 	}
 
-	return os.WriteFile(filepath.Join(t.TmpDirName, "Dockerfile"), dockerFile.Bytes(), 0700)
+	if root != "" {
+		if cleaned == root {
+			return "", nil
+		}
+		cleaned = strings.TrimPrefix(cleaned, root+"/")
+	}
+	return path.Join(prefix, cleaned), nil
 }
 
-// ReadTar is a helper utility to read contents of in memory tar file
+// ReadTar is a helper utility to read contents of a tar stream
 // and is mostly used for debugging and testing
-func ReadTar(buf *bytes.Buffer) error {
-	tr := tar.NewReader(buf)
+func ReadTar(r io.Reader) error {
+	tr := tar.NewReader(r)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -129,34 +229,95 @@ func ReadTar(buf *bytes.Buffer) error {
 	return nil
 }
 
-// BuildContextTar is a wrapper function tht builds a tar ball with Dockerfile and contents of bundle
+// BuildContextTar is a wrapper function that streams a tar ball with Dockerfile and contents of bundle
 // and this can be passed to image builder to ensure support bundle kit image is layered with
-// actual support bundle contents to allow for subsequent processing by simulator
-func BuildContextTar(bundlePath string, baseImage string) (*bytes.Buffer, error) {
-	t, err := NewTarHandler()
+// actual support bundle contents to allow for subsequent processing by simulator.
+// The tar is generated as the returned reader is consumed, and any error generating the tar
+// is returned from Read. Callers must Close the reader to release resources
+func BuildContextTar(ctx context.Context, bundlePath string, baseImage string) io.ReadCloser {
+	t := NewTarHandler(ctx, bundlePath, baseImage)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(t.WriteContext(pw))
+	}()
+	return pr
+}
+
+// BuildBundleTar streams a tar ball with just the contents of the bundle in the bundle directory,
+// which can be copied into a volume. Callers must Close the reader to release resources
+func BuildBundleTar(ctx context.Context, bundlePath string) io.ReadCloser {
+	t := NewTarHandler(ctx, bundlePath, "")
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(t.WriteBundle(tar.NewWriter(pw)))
+	}()
+	return pr
+}
+
+// BundleDigest returns the hex encoded sha256 digest of the bundle file, and stops reading the bundle once ctx is cancelled.
+// The digest of a directory is generated from the names, modes and contents of all files in the directory
+func BundleDigest(ctx context.Context, bundlePath string) (string, error) {
+	info, err := os.Stat(bundlePath)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("error opening bundle %s: %w", bundlePath, err)
 	}
 
-	// prepare zip file and extract it into bundle folder
-	if err := t.UnzipSupportBundle(bundlePath); err != nil {
-		return nil, err
+	h := sha256.New()
+	if info.IsDir() {
+		err = digestDirectory(ctx, h, bundlePath)
+	} else {
+		err = digestFile(ctx, h, bundlePath)
 	}
-
-	// add Dockerfile to root of tar image
-	if err := t.AddDockerFile(baseImage); err != nil {
-		return nil, err
+	if err != nil {
+		return "", fmt.Errorf("error generating digest for bundle %s: %w", bundlePath, err)
 	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	buf, err := t.GenerateBundleTar()
+// digestFile writes the contents of the file at filePath to w
+func digestFile(ctx context.Context, w io.Writer, filePath string) error {
+	f, err := os.Open(filePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer f.Close()
+
+	_, err = io.Copy(w, &contextReader{ctx: ctx, r: f})
+	return err
+}
+
+// digestDirectory writes the name and mode of every entry below dir to w, followed by the contents of regular files
+func digestDirectory(ctx context.Context, w io.Writer, dir string) error {
+	return walkDirectory(dir, func(e bundleEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\x00%s\x00%d\x00", e.name, e.mode, e.size)
+		if !e.mode.IsRegular() {
+			return nil
+		}
+		f, err := e.open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, &contextReader{ctx: ctx, r: f})
+		return err
+	})
+}
+
+// contextReader returns the error of ctx once it is cancelled, so copying large files stops promptly
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
 
-	if err := t.Cleanup(); err != nil {
-		return nil, fmt.Errorf("error cleaning up tmp directory: %v", err)
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return buf, err
+	return c.r.Read(p)
 }
 
 func generateTemplate(baseImage string) (bytes.Buffer, error) {
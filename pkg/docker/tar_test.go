@@ -2,7 +2,11 @@ package docker
 
 import (
 	"archive/tar"
+	"archive/zip"
+	"context"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,8 +14,8 @@ import (
 
 func Test_BuildContextTar(t *testing.T) {
 	assert := require.New(t)
-	buf, err := BuildContextTar("testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", "rancher/support-bundle-kit:master")
-	assert.NoError(err)
+	buf := BuildContextTar(context.TODO(), "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", "rancher/support-bundle-kit:master")
+	defer buf.Close()
 	tr := tar.NewReader(buf)
 	var dockerFileFound bool
 	for {
@@ -27,3 +31,134 @@ func Test_BuildContextTar(t *testing.T) {
 	}
 	assert.True(dockerFileFound, "expected to find dockerfile")
 }
+
+// writeTestZip generates a zip file containing files, keyed by name, in a temp directory
+func writeTestZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	zipFile := filepath.Join(t.TempDir(), "supportbundle_test.zip")
+	f, err := os.Create(zipFile)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return zipFile
+}
+
+// readTestTar returns the contents of regular files in the tar stream keyed by name
+func readTestTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(contents)
+	}
+	return files
+}
+
+func Test_StreamContextTar(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name: "bundle with top level directory",
+			files: map[string]string{
+				"supportbundle_test/metadata.yaml":      "metadata",
+				"supportbundle_test/yamls/cluster.yaml": "cluster",
+			},
+			expected: map[string]string{
+				"bundle/metadata.yaml":      "metadata",
+				"bundle/yamls/cluster.yaml": "cluster",
+			},
+		},
+		{
+			name: "bundle without top level directory",
+			files: map[string]string{
+				"metadata.yaml":      "metadata",
+				"yamls/cluster.yaml": "cluster",
+			},
+			expected: map[string]string{
+				"bundle/metadata.yaml":      "metadata",
+				"bundle/yamls/cluster.yaml": "cluster",
+			},
+		},
+		{
+			name: "bundle with path traversal",
+			files: map[string]string{
+				"../metadata.yaml": "metadata",
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			contextTar := BuildContextTar(context.TODO(), writeTestZip(t, tt.files), "rancher/support-bundle-kit:master")
+			defer contextTar.Close()
+			if tt.expectError {
+				_, err := io.Copy(io.Discard, contextTar)
+				assert.Error(err)
+				return
+			}
+
+			files := readTestTar(t, contextTar)
+			assert.Contains(files["Dockerfile"], "FROM rancher/support-bundle-kit:master")
+			delete(files, "Dockerfile")
+			assert.Equal(tt.expected, files)
+		})
+	}
+}
+
+func Test_BuildBundleTar(t *testing.T) {
+	assert := require.New(t)
+	zipFile := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+	bundleTar := BuildBundleTar(context.TODO(), zipFile)
+	defer bundleTar.Close()
+	files := readTestTar(t, bundleTar)
+	assert.Equal(map[string]string{"bundle/metadata.yaml": "metadata"}, files)
+
+	digest, err := BundleDigest(context.TODO(), zipFile)
+	assert.NoError(err)
+	assert.Len(digest, 64)
+	again, err := BundleDigest(context.TODO(), zipFile)
+	assert.NoError(err)
+	assert.Equal(digest, again, "expected digest to be stable")
+}
+
+func Test_BuildBundleTarCancelled(t *testing.T) {
+	assert := require.New(t)
+	zipFile := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	bundleTar := BuildBundleTar(ctx, zipFile)
+	defer bundleTar.Close()
+	_, err := io.ReadAll(bundleTar)
+	assert.ErrorIs(err, context.Canceled)
+
+	_, err = BundleDigest(ctx, zipFile)
+	assert.ErrorIs(err, context.Canceled)
+}
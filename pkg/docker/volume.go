@@ -1,18 +1,85 @@
 package docker
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"path"
+	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
 	"github.com/sirupsen/logrus"
 )
 
-func (c *Client) CreateVolume(name string) error {
+const (
+	bundleVolumePrefix = "sim-cli-bundle"
+	bundleDigestKey    = simCliPrefix + "/bundle-digest"
+	bundleMountPath    = "/bundle"
+	// populatedMarker is written to the root of a bundle volume once the bundle has been fully extracted
+	populatedMarker = ".sim-cli-populated"
+)
+
+// CreateBundleVolume extracts the support bundle into a volume which can be mounted into simulator
+// containers. Volumes are named after the digest of the bundle, and an existing volume for the same
+// bundle is reused
+func (c *Client) CreateBundleVolume(bundlePath string, digest string, baseImage string) (string, error) {
+	if len(digest) < 12 {
+		return "", fmt.Errorf("invalid digest %q for bundle %s", digest, bundlePath)
+	}
+
+	name := fmt.Sprintf("%s-%s", bundleVolumePrefix, digest[:12])
+	existing, err := c.APIClient.VolumeInspect(c.ctx, name)
+	switch {
+	case err == nil && existing.Labels[bundleDigestKey] == digest:
+		populated, err := c.volumePopulated(name, baseImage)
+		if err != nil {
+			return "", err
+		}
+		if populated {
+			logrus.Infof("reusing volume %s for bundle %s", name, bundlePath)
+			return name, nil
+		}
+
+		// volumes left behind by an earlier create which was killed before the bundle was extracted
+		logrus.Warnf("volume %s for bundle %s was not fully populated, recreating it", name, bundlePath)
+		if err := c.APIClient.VolumeRemove(c.ctx, name, false); err != nil {
+			return "", fmt.Errorf("error removing partially populated volume %s: %w", name, err)
+		}
+	case err != nil && !errdefs.IsNotFound(err):
+		return "", fmt.Errorf("error inspecting volume %s: %w", name, err)
+	}
+
+	if err := c.CreateVolume(name, bundlePath, digest); err != nil {
+		return "", err
+	}
+
+	logrus.Infof("extracting bundle %s into volume %s", bundlePath, name)
+	if err := c.populateVolume(name, bundlePath, baseImage); err != nil {
+		// the volume is removed even if the command was interrupted or timed out
+		if removeErr := c.APIClient.VolumeRemove(context.WithoutCancel(c.ctx), name, true); removeErr != nil {
+			logrus.WithError(removeErr).Warnf("error removing partially populated volume %s", name)
+		}
+		return "", fmt.Errorf("error extracting bundle into volume %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// CreateVolume creates a volume labelled with the details of the bundle it will contain
+func (c *Client) CreateVolume(name, bundlePath, digest string) error {
 	volume, err := c.APIClient.VolumeCreate(c.ctx, volume.CreateOptions{
 		Name:   name,
 		Driver: "local",
 		Labels: map[string]string{
-			bundleNameKey: "name",
+			runtime.BundleNameLabel: bundlePath,
+			bundleDigestKey:         digest,
 		},
 	})
 	if err != nil {
@@ -22,6 +89,139 @@ func (c *Client) CreateVolume(name string) error {
 	return nil
 }
 
+// populateVolume copies the contents of the bundle into the volume using a short-lived helper container,
+// which is never started, with the volume mounted at the bundle path. A marker is written to the volume
+// once the whole bundle has been copied
+func (c *Client) populateVolume(name, bundlePath, baseImage string) error {
+	id, err := c.createHelperContainer(name, baseImage)
+	if err != nil {
+		return err
+	}
+	defer c.removeHelperContainer(id)
+
+	bundleTar := BuildBundleTar(c.ctx, bundlePath)
+	defer bundleTar.Close()
+	if err := c.APIClient.CopyToContainer(c.ctx, id, "/", bundleTar, container.CopyToContainerOptions{}); err != nil {
+		return err
+	}
+
+	marker, err := populatedMarkerTar()
+	if err != nil {
+		return err
+	}
+	return c.APIClient.CopyToContainer(c.ctx, id, "/", marker, container.CopyToContainerOptions{})
+}
+
+// volumePopulated checks if the bundle was fully extracted into the volume
+func (c *Client) volumePopulated(name, baseImage string) (bool, error) {
+	id, err := c.createHelperContainer(name, baseImage)
+	if err != nil {
+		return false, err
+	}
+	defer c.removeHelperContainer(id)
+
+	markerPath := path.Join(bundleMountPath, populatedMarker)
+	if _, err := c.APIClient.ContainerStatPath(c.ctx, id, markerPath); err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking %s in volume %s: %w", markerPath, name, err)
+	}
+	return true, nil
+}
+
+// populatedMarkerTar returns a tar archive containing the populated marker in the bundle directory
+func populatedMarkerTar() (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(defaultBundleDir, populatedMarker),
+		Mode:     0644,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("error writing populated marker: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("error writing populated marker: %w", err)
+	}
+	return buf, nil
+}
+
+// createHelperContainer creates a container, which is never started, with the volume mounted at the bundle path
+func (c *Client) createHelperContainer(name, baseImage string) (string, error) {
+	resp, err := c.APIClient.ContainerCreate(c.ctx, &container.Config{
+		Image: baseImage,
+		Cmd:   []string{"true"},
+	}, &container.HostConfig{
+		Mounts: []mount.Mount{
+			{
+				Type:   mount.TypeVolume,
+				Source: name,
+				Target: bundleMountPath,
+			},
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("error creating helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// removeHelperContainer removes the helper container, even if the command was interrupted or timed out
+func (c *Client) removeHelperContainer(id string) {
+	if err := c.APIClient.ContainerRemove(context.WithoutCancel(c.ctx), id, container.RemoveOptions{Force: true}); err != nil {
+		logrus.WithError(err).Warnf("error removing helper container %s", id)
+	}
+}
+
+// RemoveVolume removes the bundle volume, unless it is still mounted by other containers
 func (c *Client) RemoveVolume(name string) error {
+	containers, err := c.APIClient.ContainerList(c.ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.KeyValuePair{Key: "volume", Value: name}),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing containers using volume %s: %w", name, err)
+	}
+
+	if len(containers) != 0 {
+		logrus.Infof("volume %s is still in use by %d containers, skipping removal", name, len(containers))
+		return nil
+	}
+
+	if err := c.APIClient.VolumeRemove(c.ctx, name, false); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("error removing volume %s: %w", name, err)
+	}
+	logrus.Infof("removed volume: %s", name)
 	return nil
 }
+
+// FindAllBundleVolumes returns all volumes labelled with a bundle, along with the disk space they use
+func (c *Client) FindAllBundleVolumes() ([]runtime.Volume, error) {
+	usage, err := c.APIClient.DiskUsage(c.ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.VolumeObject},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching volume disk usage: %w", err)
+	}
+
+	var volumes []runtime.Volume
+	for _, v := range usage.Volumes {
+		bundlePath, ok := v.Labels[runtime.BundleNameLabel]
+		if !ok {
+			continue
+		}
+
+		size := int64(-1)
+		if v.UsageData != nil {
+			size = v.UsageData.Size
+		}
+		volumes = append(volumes, runtime.Volume{
+			Name:       v.Name,
+			BundlePath: bundlePath,
+			Size:       size,
+		})
+	}
+	return volumes, nil
+}
@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FindAllBundleVolumes(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	api.volumes = []*volume.Volume{
+		{
+			Name:      "sim-cli-bundle-abc",
+			Labels:    map[string]string{runtime.BundleNameLabel: "/tmp/bundle.zip"},
+			UsageData: &volume.UsageData{Size: 1024, RefCount: 0},
+		},
+		{
+			Name:   "sim-cli-bundle-def",
+			Labels: map[string]string{runtime.BundleNameLabel: "/tmp/other.zip"},
+		},
+		{
+			Name: "unrelated",
+		},
+	}
+
+	volumes, err := client.FindAllBundleVolumes()
+	assert.NoError(err)
+	assert.Equal([]runtime.Volume{
+		{Name: "sim-cli-bundle-abc", BundlePath: "/tmp/bundle.zip", Size: 1024},
+		{Name: "sim-cli-bundle-def", BundlePath: "/tmp/other.zip", Size: -1},
+	}, volumes)
+}
+
+func Test_CreateBundleVolume(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+
+	name, err := client.CreateBundleVolume(bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.Equal("sim-cli-bundle-"+testBundleDigest[:12], name)
+	assert.Equal([]string{"metadata.yaml", populatedMarker}, api.volumeFiles[name], "expected marker to be written after the bundle")
+	assert.Empty(api.containers, "expected helper container to be removed")
+
+	again, err := client.CreateBundleVolume(bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.Equal(name, again)
+	assert.Len(api.volumes, 1, "expected volume to be reused")
+	assert.Empty(api.containers, "expected helper container to be removed")
+
+	_, err = client.CreateBundleVolume(bundlePath, "7d0deb", "rancher/support-bundle-kit:dev")
+	assert.Error(err, "expected error for invalid digest")
+}
+
+func Test_CreateBundleVolumeInterrupted(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	client.ctx = ctx
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+
+	api.onCopy = cancel
+	_, err := client.CreateBundleVolume(bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev")
+	assert.ErrorIs(err, context.Canceled)
+	assert.Empty(api.volumes, "expected partially populated volume to be removed")
+	assert.Empty(api.containers, "expected helper container to be removed")
+}
+
+func Test_CreateBundleVolumeNotPopulated(t *testing.T) {
+	assert := require.New(t)
+	client, api := newTestClient()
+	bundlePath := writeTestZip(t, map[string]string{
+		"supportbundle_test/metadata.yaml": "metadata",
+	})
+
+	// volume left behind by a create which was killed while extracting the bundle
+	name := "sim-cli-bundle-" + testBundleDigest[:12]
+	assert.NoError(client.CreateVolume(name, bundlePath, testBundleDigest))
+	api.volumeFiles[name] = []string{"metadata.yaml"}
+
+	again, err := client.CreateBundleVolume(bundlePath, testBundleDigest, "rancher/support-bundle-kit:dev")
+	assert.NoError(err)
+	assert.Equal(name, again)
+	assert.Len(api.volumes, 1)
+	assert.Equal([]string{"metadata.yaml", populatedMarker}, api.volumeFiles[name], "expected volume to be populated again")
+	assert.Empty(api.containers, "expected helper containers to be removed")
+}
@@ -2,8 +2,14 @@ package kubeconfig
 
 import (
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"sort"
+	"time"
 
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
@@ -16,7 +22,7 @@ func AddContext(fileName string, name, endpoint, port string, contents []byte) e
 		return fmt.Errorf("failed to read existing kubeconfig file %s: %w", fileName, err)
 	}
 
-	existingConfig := &api.Config{}
+	existingConfig := api.NewConfig()
 	if len(existingContent) > 0 {
 		existingConfig, err = clientcmd.Load(existingContent)
 		if err != nil {
@@ -42,7 +48,7 @@ func configureKubeConfig(contents []byte, name, endpoint, port string) (*api.Con
 	}
 
 	// rename user to admin@name
-	config.Clusters["default"].Server = fmt.Sprintf("https://%s:%s", endpoint, port)
+	config.Clusters["default"].Server = ServerAddress(endpoint, port)
 	newAuthInfoName := fmt.Sprintf("admin@%s", name)
 	config.AuthInfos[newAuthInfoName] = config.AuthInfos["default"]
 	delete(config.AuthInfos, "default")
@@ -107,3 +113,93 @@ func RemoveContext(fileName, instanceName string) error {
 	delete(config.AuthInfos, instanceName)
 	return clientcmd.WriteToFile(*config, fileName)
 }
+
+// Contexts returns the names of the contexts in the kubeconfig file, or no contexts if the file does not exist
+func Contexts(fileName string) ([]string, error) {
+	existingContent, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read existing kubeconfig file %s: %w", fileName, err)
+	}
+
+	config, err := clientcmd.Load(existingContent)
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+
+	var contexts []string
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// ServerURL returns the server url of the cluster referenced by context name in the kubeconfig file,
+// or an empty string if the file or context does not exist
+func ServerURL(fileName, name string) (string, error) {
+	existingContent, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read existing kubeconfig file %s: %w", fileName, err)
+	}
+
+	config, err := clientcmd.Load(existingContent)
+	if err != nil {
+		return "", fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+
+	context, ok := config.Contexts[name]
+	if !ok {
+		return "", nil
+	}
+
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return "", nil
+	}
+	return cluster.Server, nil
+}
+
+// ServerAddress returns the server url for an api server reachable at endpoint and port
+func ServerAddress(endpoint, port string) string {
+	return fmt.Sprintf("https://%s", net.JoinHostPort(endpoint, port))
+}
+
+// CheckReadyz queries the /readyz endpoint of the api server referenced by context name in the kubeconfig file
+// and returns an error if the api server is unreachable or not ready yet
+func CheckReadyz(fileName, name string, timeout time.Duration) error {
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: fileName},
+		&clientcmd.ConfigOverrides{CurrentContext: name},
+	).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("error loading context %s from kubeconfig %s: %w", name, fileName, err)
+	}
+
+	restConfig.Timeout = timeout
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return fmt.Errorf("error generating http client for context %s: %w", name, err)
+	}
+
+	resp, err := httpClient.Get(restConfig.Host + "/readyz")
+	if err != nil {
+		return fmt.Errorf("error querying readyz endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading readyz response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("readyz endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
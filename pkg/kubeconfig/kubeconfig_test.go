@@ -1,8 +1,13 @@
 package kubeconfig
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -20,3 +25,72 @@ func Test_Config(t *testing.T) {
 	assert.True(config.Clusters[name].InsecureSkipTLSVerify, "expected to find insecure access setup")
 	assert.Nil(config.Clusters[name].CertificateAuthorityData, "expected to not find any certificate-authority-data")
 }
+
+func Test_CheckReadyz(t *testing.T) {
+	name := "issue-113"
+	assert := require.New(t)
+	ready := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !ready {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(err)
+	contents, err := os.ReadFile("testdata/admin.kubeconfig")
+	assert.NoError(err)
+	fileName := filepath.Join(t.TempDir(), "admin.kubeconfig")
+	err = AddContext(fileName, name, serverURL.Hostname(), serverURL.Port(), contents)
+	assert.NoError(err)
+
+	err = CheckReadyz(fileName, name, 5*time.Second)
+	assert.Error(err, "expected error when api server is not ready")
+	ready = true
+	err = CheckReadyz(fileName, name, 5*time.Second)
+	assert.NoError(err, "expected no error when api server is ready")
+	err = CheckReadyz(fileName, "missing", 5*time.Second)
+	assert.Error(err, "expected error for a missing context")
+}
+
+func Test_ServerURL(t *testing.T) {
+	name := "issue-113"
+	assert := require.New(t)
+	fileName := filepath.Join(t.TempDir(), "admin.kubeconfig")
+	server, err := ServerURL(fileName, name)
+	assert.NoError(err, "expected no error for missing kubeconfig")
+	assert.Empty(server)
+
+	contents, err := os.ReadFile("testdata/admin.kubeconfig")
+	assert.NoError(err)
+	assert.NoError(AddContext(fileName, name, "localhost", "32217", contents))
+	server, err = ServerURL(fileName, name)
+	assert.NoError(err)
+	assert.Equal("https://localhost:32217", server)
+	server, err = ServerURL(fileName, "missing")
+	assert.NoError(err)
+	assert.Empty(server, "expected no server for missing context")
+}
+
+func Test_Contexts(t *testing.T) {
+	assert := require.New(t)
+	fileName := filepath.Join(t.TempDir(), "admin.kubeconfig")
+	contexts, err := Contexts(fileName)
+	assert.NoError(err, "expected no error for missing kubeconfig")
+	assert.Empty(contexts)
+
+	contents, err := os.ReadFile("testdata/admin.kubeconfig")
+	assert.NoError(err)
+	assert.NoError(AddContext(fileName, "issue-7007", "localhost", "32217", contents))
+	assert.NoError(AddContext(fileName, "issue-113", "localhost", "32218", contents))
+	contexts, err = Contexts(fileName)
+	assert.NoError(err)
+	assert.Equal([]string{"issue-113", "issue-7007"}, contexts)
+}
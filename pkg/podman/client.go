@@ -0,0 +1,53 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ibrokethecloud/sim-cli/pkg/docker"
+)
+
+const (
+	// podman tags locally built images in the localhost registry
+	imageRepository  = "localhost/sim-cli-managed"
+	rootfulSocket    = "/run/podman/podman.sock"
+	containerHostEnv = "CONTAINER_HOST"
+)
+
+// NewClient initialises a client for the docker compatible api exposed by the podman service.
+// The podman socket is identified from CONTAINER_HOST, or the default rootless and rootful
+// socket locations, unless a host or context is specified in opts
+func NewClient(ctx context.Context, opts docker.ClientOptions) (*docker.Client, error) {
+	if opts.Host == "" && opts.Context == "" {
+		host, err := findSocket()
+		if err != nil {
+			return nil, err
+		}
+		opts.Host = host
+	}
+
+	opts.ImageRepository = imageRepository
+	return docker.NewClient(ctx, opts)
+}
+
+// findSocket returns the address of the podman api socket
+func findSocket() (string, error) {
+	if host := os.Getenv(containerHostEnv); host != "" {
+		return host, nil
+	}
+
+	var candidates []string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, rootfulSocket)
+
+	for _, v := range candidates {
+		if _, err := os.Stat(v); err == nil {
+			return "unix://" + v, nil
+		}
+	}
+	return "", fmt.Errorf("unable to find podman socket in %v, ensure podman.socket service is running or set %s", candidates, containerHostEnv)
+}
@@ -0,0 +1,27 @@
+package podman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findSocket(t *testing.T) {
+	assert := require.New(t)
+	t.Setenv(containerHostEnv, "ssh://core@localhost:2222/run/user/1000/podman/podman.sock")
+	host, err := findSocket()
+	assert.NoError(err)
+	assert.Equal("ssh://core@localhost:2222/run/user/1000/podman/podman.sock", host)
+
+	runtimeDir := t.TempDir()
+	t.Setenv(containerHostEnv, "")
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+	assert.NoError(os.MkdirAll(filepath.Dir(socket), 0700))
+	assert.NoError(os.WriteFile(socket, nil, 0600))
+	host, err = findSocket()
+	assert.NoError(err)
+	assert.Equal("unix://"+socket, host)
+}
@@ -0,0 +1,707 @@
+package fake
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/ibrokethecloud/sim-cli/pkg/runtime"
+)
+
+const (
+	basePort = 32768
+)
+
+// ensure Runtime can be used as a container runtime
+var _ runtime.Runtime = &Runtime{}
+
+// Image is an image recorded by the fake runtime
+type Image struct {
+	ID           string
+	Name         string
+	BaseImage    string
+	BundlePath   string
+	BundleDigest string
+	Labels       map[string]string
+	Size         int64
+}
+
+// Volume is a bundle volume recorded by the fake runtime
+type Volume struct {
+	Name         string
+	BundlePath   string
+	BundleDigest string
+	Size         int64
+}
+
+// Container is a container recorded by the fake runtime
+type Container struct {
+	ID          string
+	Name        string
+	Image       string
+	Labels      map[string]string
+	Volume      string
+	BindAddress string
+	Port        uint16
+	Running     bool
+	// Unpublished hides the published api server port of a running container
+	Unpublished bool
+	// State overrides the state reported for the container, like created or dead
+	State     string
+	ExitCode  int
+	OOMKilled bool
+	// RestartCount is the number of times the container was restarted by the runtime
+	RestartCount int
+	Files        map[string][]byte
+	Logs         string
+}
+
+// Runtime is an in memory container runtime, which records images and containers created by sim-cli
+// and allows errors to be injected for each operation to simplify testing of instance lifecycle
+type Runtime struct {
+	// Images are keyed by instance name
+	Images map[string]*Image
+	// BaseImages are the images present in the local image store, keyed by reference
+	BaseImages map[string]bool
+	// Containers are keyed by instance name
+	Containers map[string]*Container
+	// Volumes are keyed by volume name
+	Volumes map[string]*Volume
+	// Files are copied into every new container, and are keyed by path
+	Files map[string][]byte
+	// Logs are used as the logs of every new container
+	Logs string
+	// Endpoint is the host reported for exposed ports, defaults to localhost
+	Endpoint string
+	// Host is the address of the daemon, defaults to unix:///var/run/docker.sock
+	Host string
+	// ExecOutput is written to stdout for every command run in a container
+	ExecOutput string
+	// ExecExitCode is the exit code returned for every command run in a container
+	ExecExitCode int
+	// Execs records the commands run in containers keyed by instance name
+	Execs map[string][][]string
+	// OnRun is called with every new container, and can be used to simulate containers which fail to start
+	OnRun func(c *Container)
+	// Ctx fails all operations once it is cancelled, like the context of a docker client
+	Ctx context.Context
+	// Errors are returned by the operation matching the key, for example "RunContainer"
+	Errors map[string]error
+	// Calls records the name of each operation invoked
+	Calls []string
+
+	// NextPort is the port allocated to the next container which does not request a host port
+	NextPort uint16
+
+	mu     sync.Mutex
+	nextID int
+}
+
+// NewRuntime returns an empty fake runtime
+func NewRuntime() *Runtime {
+	return &Runtime{
+		Images:     make(map[string]*Image),
+		BaseImages: make(map[string]bool),
+		Containers: make(map[string]*Container),
+		Volumes:    make(map[string]*Volume),
+		Files:      make(map[string][]byte),
+		Errors:     make(map[string]error),
+		Execs:      make(map[string][][]string),
+		Endpoint:   "localhost",
+		Host:       "unix:///var/run/docker.sock",
+		NextPort:   basePort,
+	}
+}
+
+// record tracks invocation of operation and returns any error injected for it
+func (r *Runtime) record(operation string) error {
+	r.Calls = append(r.Calls, operation)
+	if r.Ctx != nil && r.Ctx.Err() != nil {
+		return r.Ctx.Err()
+	}
+	return r.Errors[operation]
+}
+
+func (r *Runtime) CreateImage(instanceName string, bundlePath string, bundleDigest string, baseImage string, labels map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("CreateImage"); err != nil {
+		return err
+	}
+
+	imageLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		imageLabels[k] = v
+	}
+	imageLabels[runtime.BundleNameLabel] = instanceName
+
+	r.nextID++
+	r.Images[instanceName] = &Image{
+		ID:           fmt.Sprintf("sha256:%064d", r.nextID),
+		Name:         fmt.Sprintf("%s:%s", runtime.InstanceLabel, instanceName),
+		BaseImage:    baseImage,
+		BundlePath:   bundlePath,
+		BundleDigest: bundleDigest,
+		Labels:       imageLabels,
+	}
+	return nil
+}
+
+func (r *Runtime) PullImage(ref string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("PullImage"); err != nil {
+		return err
+	}
+
+	r.BaseImages[ref] = true
+	return nil
+}
+
+// ImageDigest returns a digest generated from ref for images present in BaseImages
+func (r *Runtime) ImageDigest(ref string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("ImageDigest"); err != nil {
+		return "", err
+	}
+
+	if !r.BaseImages[ref] {
+		return "", fmt.Errorf("no such image: %s", ref)
+	}
+	return fmt.Sprintf("%s@sha256:%x", ref, sha256.Sum256([]byte(ref))), nil
+}
+
+// InspectImage returns the details of an image built for an instance, or a base image
+func (r *Runtime) InspectImage(ref string) (types.ImageInspect, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("InspectImage"); err != nil {
+		return types.ImageInspect{}, err
+	}
+
+	for _, v := range r.Images {
+		if v.Name == ref || v.ID == ref {
+			return types.ImageInspect{
+				ID:       v.ID,
+				RepoTags: []string{v.Name},
+				Size:     v.Size,
+				Config:   &container.Config{Labels: v.Labels},
+				RootFS: types.RootFS{
+					Type:   "layers",
+					Layers: []string{fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(v.BaseImage))), fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(v.BundlePath)))},
+				},
+			}, nil
+		}
+	}
+
+	if r.BaseImages[ref] {
+		return types.ImageInspect{
+			ID:       fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(ref))),
+			RepoTags: []string{ref},
+			RootFS: types.RootFS{
+				Type:   "layers",
+				Layers: []string{fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(ref)))},
+			},
+		}, nil
+	}
+	return types.ImageInspect{}, fmt.Errorf("no such image: %s", ref)
+}
+
+func (r *Runtime) ImageExists(ref string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("ImageExists"); err != nil {
+		return false, err
+	}
+
+	return r.BaseImages[ref], nil
+}
+
+// SaveImage writes the reference of the image to w
+func (r *Runtime) SaveImage(ref string, w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("SaveImage"); err != nil {
+		return err
+	}
+
+	if !r.BaseImages[ref] {
+		return fmt.Errorf("no such image: %s", ref)
+	}
+	_, err := io.WriteString(w, ref)
+	return err
+}
+
+// LoadImage reads the reference of an image written by SaveImage from rd
+func (r *Runtime) LoadImage(rd io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("LoadImage"); err != nil {
+		return err
+	}
+
+	ref, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	r.BaseImages[string(ref)] = true
+	return nil
+}
+
+func (r *Runtime) FindAllSimManagedImages() ([]runtime.Image, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("FindAllSimManagedImages"); err != nil {
+		return nil, err
+	}
+
+	var images []runtime.Image
+	for instanceName, v := range r.Images {
+		images = append(images, runtime.Image{
+			ID:        v.ID,
+			Instances: []string{instanceName},
+			Size:      v.Size,
+		})
+	}
+	return images, nil
+}
+
+func (r *Runtime) RemoveImages(instanceName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("RemoveImages"); err != nil {
+		return err
+	}
+
+	delete(r.Images, instanceName)
+	return nil
+}
+
+func (r *Runtime) CreateBundleVolume(bundlePath string, bundleDigest string, baseImage string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("CreateBundleVolume"); err != nil {
+		return "", err
+	}
+
+	for _, v := range r.Volumes {
+		if v.BundleDigest == bundleDigest {
+			return v.Name, nil
+		}
+	}
+
+	name := fmt.Sprintf("sim-cli-bundle-%d", len(r.Volumes)+1)
+	r.Volumes[name] = &Volume{
+		Name:         name,
+		BundlePath:   bundlePath,
+		BundleDigest: bundleDigest,
+	}
+	return name, nil
+}
+
+func (r *Runtime) FindAllBundleVolumes() ([]runtime.Volume, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("FindAllBundleVolumes"); err != nil {
+		return nil, err
+	}
+
+	var volumes []runtime.Volume
+	for _, v := range r.Volumes {
+		volumes = append(volumes, runtime.Volume{
+			Name:       v.Name,
+			BundlePath: v.BundlePath,
+			Size:       v.Size,
+		})
+	}
+	return volumes, nil
+}
+
+func (r *Runtime) RemoveVolume(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("RemoveVolume"); err != nil {
+		return err
+	}
+
+	for _, v := range r.Containers {
+		if v.Volume == name {
+			return nil
+		}
+	}
+	delete(r.Volumes, name)
+	return nil
+}
+
+func (r *Runtime) RunContainer(opts runtime.RunOptions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("RunContainer"); err != nil {
+		return err
+	}
+
+	instanceName := opts.InstanceName
+	imageName := opts.Image
+	if imageName == "" {
+		image, ok := r.Images[instanceName]
+		if !ok {
+			return fmt.Errorf("no image found for instance %s", instanceName)
+		}
+		imageName = image.Name
+	}
+
+	labels := opts.Metadata.Labels()
+	labels[runtime.BundleNameLabel] = opts.BundlePath
+	labels[runtime.InstanceLabel] = instanceName
+	labels[runtime.BindAddressLabel] = opts.BindAddress
+
+	port := r.NextPort
+	if opts.HostPort != 0 {
+		port = uint16(opts.HostPort)
+		labels[runtime.HostPortLabel] = fmt.Sprintf("%d", opts.HostPort)
+	}
+
+	for _, v := range r.Containers {
+		if v.Port == port {
+			return fmt.Errorf("port %d is already allocated", port)
+		}
+	}
+
+	if opts.Volume != "" {
+		if _, ok := r.Volumes[opts.Volume]; !ok {
+			return fmt.Errorf("no volume found with name %s", opts.Volume)
+		}
+		labels[runtime.BundleVolumeLabel] = opts.Volume
+	}
+
+	if opts.NanoCPUs != 0 {
+		labels[runtime.CPUsLabel] = strconv.FormatInt(opts.NanoCPUs, 10)
+	}
+	if opts.Memory != 0 {
+		labels[runtime.MemoryLabel] = strconv.FormatInt(opts.Memory, 10)
+	}
+
+	if _, ok := r.Containers[instanceName]; ok {
+		return fmt.Errorf("container with name %s already exists", instanceName)
+	}
+
+	files := make(map[string][]byte, len(r.Files))
+	for k, v := range r.Files {
+		files[k] = v
+	}
+
+	r.nextID++
+	r.Containers[instanceName] = &Container{
+		ID:          fmt.Sprintf("%064d", r.nextID),
+		Name:        instanceName,
+		Image:       imageName,
+		Labels:      labels,
+		Volume:      opts.Volume,
+		BindAddress: opts.BindAddress,
+		Port:        port,
+		Running:     true,
+		Files:       files,
+		Logs:        r.Logs,
+	}
+	if r.OnRun != nil {
+		r.OnRun(r.Containers[instanceName])
+	}
+	if opts.HostPort == 0 {
+		r.NextPort++
+	}
+	return nil
+}
+
+func (r *Runtime) FindRunningContainer(instanceName string) ([]types.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("FindRunningContainer"); err != nil {
+		return nil, err
+	}
+
+	c, ok := r.Containers[instanceName]
+	if !ok || !c.Running {
+		return nil, nil
+	}
+	return []types.Container{c.toContainer()}, nil
+}
+
+func (r *Runtime) FindContainer(instanceName string) ([]types.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("FindContainer"); err != nil {
+		return nil, err
+	}
+
+	c, ok := r.Containers[instanceName]
+	if !ok {
+		return nil, nil
+	}
+	return []types.Container{c.toContainer()}, nil
+}
+
+func (r *Runtime) InspectContainer(instanceName string) (types.ContainerJSON, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("InspectContainer"); err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	c, ok := r.Containers[instanceName]
+	if !ok {
+		return types.ContainerJSON{}, fmt.Errorf("expected one container for instance %s, got 0", instanceName)
+	}
+
+	summary := c.toContainer()
+	ports := make(nat.PortMap)
+	for _, v := range summary.Ports {
+		ports[nat.Port(fmt.Sprintf("%d/tcp", v.PrivatePort))] = []nat.PortBinding{{HostIP: v.IP, HostPort: strconv.Itoa(int(v.PublicPort))}}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:    c.ID,
+			Name:  "/" + c.Name,
+			Image: c.Image,
+			State: &types.ContainerState{
+				Status:     summary.State,
+				Running:    c.Running,
+				Restarting: summary.State == "restarting",
+				ExitCode:   c.ExitCode,
+				OOMKilled:  c.OOMKilled,
+			},
+			RestartCount: c.RestartCount,
+		},
+		Config: &container.Config{
+			Image:  c.Image,
+			Labels: c.Labels,
+		},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: ports,
+			},
+		},
+	}, nil
+}
+
+func (r *Runtime) StopContainer(instanceName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("StopContainer"); err != nil {
+		return err
+	}
+
+	if c, ok := r.Containers[instanceName]; ok {
+		c.Running = false
+	}
+	return nil
+}
+
+func (r *Runtime) StartContainer(instanceName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("StartContainer"); err != nil {
+		return err
+	}
+
+	c, ok := r.Containers[instanceName]
+	if !ok {
+		return nil
+	}
+
+	// like docker a new random port is allocated on start unless a host port was requested
+	if _, ok := c.Labels[runtime.HostPortLabel]; !ok && !c.Running {
+		c.Port = r.NextPort
+		r.NextPort++
+	}
+	c.Running = true
+	return nil
+}
+
+func (r *Runtime) RemoveContainer(instanceName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("RemoveContainer"); err != nil {
+		return err
+	}
+
+	delete(r.Containers, instanceName)
+	return nil
+}
+
+func (r *Runtime) QueryExposedMapping(instanceName string) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("QueryExposedMapping"); err != nil {
+		return "", "", err
+	}
+
+	c, err := r.runningContainer(instanceName)
+	if err != nil {
+		return "", "", err
+	}
+
+	summary := c.toContainer()
+	mapping, ok := runtime.PublishedPort(summary.Ports)
+	if !ok {
+		return "", "", fmt.Errorf("instance %s is in state %s: %w", instanceName, runtime.ContainerState(summary), runtime.ErrNoPublishedPort)
+	}
+	return r.Endpoint, fmt.Sprintf("%d", mapping.PublicPort), nil
+}
+
+func (r *Runtime) ReadFile(instanceName string, path string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("ReadFile"); err != nil {
+		return nil, err
+	}
+
+	c, err := r.runningContainer(instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, ok := c.Files[path]
+	if !ok {
+		return nil, fmt.Errorf("error reading file %s: no such file", path)
+	}
+	return contents, nil
+}
+
+func (r *Runtime) TailLogs(instanceName string, lines int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("TailLogs"); err != nil {
+		return "", err
+	}
+
+	c, ok := r.Containers[instanceName]
+	if !ok {
+		return "", fmt.Errorf("expected one container for instance %s, got 0", instanceName)
+	}
+	return c.Logs, nil
+}
+
+func (r *Runtime) StreamLogs(instanceName string, opts runtime.LogOptions, stdout, stderr io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("StreamLogs"); err != nil {
+		return err
+	}
+
+	c, ok := r.Containers[instanceName]
+	if !ok {
+		return fmt.Errorf("expected one container for instance %s, got 0", instanceName)
+	}
+	_, err := io.WriteString(stdout, c.Logs)
+	return err
+}
+
+// WithContext uses ctx for all subsequent operations. Unlike other runtimes the fake runtime is not copied,
+// so tests can inspect the outcome of all operations
+func (r *Runtime) WithContext(ctx context.Context) runtime.Runtime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Calls = append(r.Calls, "WithContext")
+	r.Ctx = ctx
+	return r
+}
+
+func (r *Runtime) DaemonHost() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Calls = append(r.Calls, "DaemonHost")
+	return r.Host
+}
+
+func (r *Runtime) Exec(instanceName string, opts runtime.ExecOptions) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("Exec"); err != nil {
+		return 0, err
+	}
+
+	if _, err := r.runningContainer(instanceName); err != nil {
+		return 0, err
+	}
+
+	r.Execs[instanceName] = append(r.Execs[instanceName], opts.Cmd)
+	if _, err := io.WriteString(opts.Stdout, r.ExecOutput); err != nil {
+		return 0, err
+	}
+	return r.ExecExitCode, nil
+}
+
+func (r *Runtime) FindAllSimManagedContainers() ([]types.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("FindAllSimManagedContainers"); err != nil {
+		return nil, err
+	}
+
+	containers := make([]types.Container, 0, len(r.Containers))
+	for _, v := range r.Containers {
+		containers = append(containers, v.toContainer())
+	}
+	return containers, nil
+}
+
+func (r *Runtime) FindAllSimManagedInstances() ([]runtime.Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record("FindAllSimManagedInstances"); err != nil {
+		return nil, err
+	}
+
+	instances := make([]runtime.Instance, 0, len(r.Containers))
+	for _, v := range r.Containers {
+		instances = append(instances, runtime.NewInstance(v.toContainer()))
+	}
+	return instances, nil
+}
+
+// runningContainer returns the running container for instanceName
+func (r *Runtime) runningContainer(instanceName string) (*Container, error) {
+	c, ok := r.Containers[instanceName]
+	if !ok || !c.Running {
+		return nil, fmt.Errorf("expected one container for instance %s, got 0", instanceName)
+	}
+	return c, nil
+}
+
+// toContainer converts the recorded container into the representation returned by the docker api
+func (c *Container) toContainer() types.Container {
+	result := types.Container{
+		ID:     c.ID,
+		Names:  []string{"/" + c.Name},
+		Image:  c.Image,
+		Labels: c.Labels,
+		State:  "exited",
+	}
+
+	// ports are only published for running containers
+	if c.Running {
+		result.State = "running"
+	}
+	if c.Running && !c.Unpublished {
+		result.Ports = []types.Port{
+			{
+				IP:          c.BindAddress,
+				PrivatePort: runtime.APIServerPort,
+				PublicPort:  c.Port,
+				Type:        "tcp",
+			},
+		}
+	}
+
+	if c.State != "" {
+		result.State = c.State
+	}
+	return result
+}
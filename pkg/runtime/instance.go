@@ -0,0 +1,112 @@
+package runtime
+
+import (
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+)
+
+// APIServerPort is the container port the simulator api server listens on
+const APIServerPort = 6443
+
+// State is the state of an instance, derived from the state of its container
+type State string
+
+const (
+	// StateRunning instances have a running container with the api server port published
+	StateRunning State = "running"
+	// StateMissingPort instances have a running container without the api server port published
+	StateMissingPort State = "missing-port"
+	// StateCreated instances have a container which was never started
+	StateCreated State = "created"
+	// StateRestarting instances have a container which is being restarted by the runtime
+	StateRestarting State = "restarting"
+	// StatePaused instances have a paused container
+	StatePaused State = "paused"
+	// StateExited instances have a stopped container
+	StateExited State = "exited"
+	// StateRemoving instances have a container which is being removed
+	StateRemoving State = "removing"
+	// StateDead instances have a container which could not be stopped or removed
+	StateDead State = "dead"
+	// StateUnknown instances have a container in a state not known to sim-cli
+	StateUnknown State = "unknown"
+)
+
+// ErrNoPublishedPort is returned when the simulator api server port of an instance is not published on the host,
+// which is the case for instances which are not running
+var ErrNoPublishedPort = errors.New("simulator api server port is not published")
+
+// ContainerState returns the state of the instance running in container c
+func ContainerState(c types.Container) State {
+	switch state := State(c.State); state {
+	case StateRunning:
+		if _, ok := PublishedPort(c.Ports); !ok {
+			return StateMissingPort
+		}
+		return StateRunning
+	case StateCreated, StateRestarting, StatePaused, StateExited, StateRemoving, StateDead:
+		return state
+	default:
+		return StateUnknown
+	}
+}
+
+// PublishedPort returns the host mapping of the simulator api server port from ports, preferring ipv4 mappings
+// when the port is published on both ipv4 and ipv6 addresses. false is returned if the port is not published
+func PublishedPort(ports []types.Port) (types.Port, bool) {
+	var mapping types.Port
+	var found bool
+	for _, v := range ports {
+		if v.PrivatePort != APIServerPort || v.PublicPort == 0 {
+			continue
+		}
+		if ip := net.ParseIP(v.IP); ip == nil || ip.To4() != nil {
+			return v, true
+		}
+		if !found {
+			mapping, found = v, true
+		}
+	}
+	return mapping, found
+}
+
+// NewInstance returns the instance running in the sim-cli managed container c
+func NewInstance(c types.Container) Instance {
+	nanoCPUs, memory := ParseLimits(c.Labels)
+	instance := Instance{
+		Name:        c.Labels[InstanceLabel],
+		ContainerID: c.ID,
+		BundlePath:  c.Labels[BundleNameLabel],
+		Image:       c.Image,
+		State:       ContainerState(c),
+		Status:      c.Status,
+		BindAddress: c.Labels[BindAddressLabel],
+		NanoCPUs:    nanoCPUs,
+		Memory:      memory,
+		Metadata:    ParseMetadata(c.Labels),
+	}
+
+	// stopped containers do not have any published ports, so fall back to the port requested at creation
+	if mapping, ok := PublishedPort(c.Ports); ok {
+		instance.Port = int(mapping.PublicPort)
+	} else if v, err := strconv.Atoi(c.Labels[HostPortLabel]); err == nil {
+		instance.Port = v
+	}
+	return instance
+}
+
+// ParseLimits returns the cpu limit in nano cpus and the memory limit in bytes recorded in the labels
+// of a container. Limits which were not applied, or cannot be parsed, are returned as 0
+func ParseLimits(labels map[string]string) (int64, int64) {
+	var nanoCPUs, memory int64
+	if v, err := strconv.ParseInt(labels[CPUsLabel], 10, 64); err == nil && v > 0 {
+		nanoCPUs = v
+	}
+	if v, err := strconv.ParseInt(labels[MemoryLabel], 10, 64); err == nil && v > 0 {
+		memory = v
+	}
+	return nanoCPUs, memory
+}
@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewInstance(t *testing.T) {
+	assert := require.New(t)
+	c := types.Container{
+		ID:     "1234567890abcdef",
+		Image:  "sim-cli-managed:issue-7007",
+		State:  "running",
+		Status: "Up 40 minutes",
+		Ports:  []types.Port{{IP: "127.0.0.1", PrivatePort: 6443, PublicPort: 30000, Type: "tcp"}},
+		Labels: map[string]string{
+			InstanceLabel:    "issue-7007",
+			BundleNameLabel:  "/tmp/supportbundle.zip",
+			BindAddressLabel: "127.0.0.1",
+			HostPortLabel:    "30001",
+			CPUsLabel:        "1500000000",
+			MemoryLabel:      "4294967296",
+			VersionLabel:     "v0.1.0",
+		},
+	}
+
+	instance := NewInstance(c)
+	assert.Equal("issue-7007", instance.Name)
+	assert.Equal(c.ID, instance.ContainerID)
+	assert.Equal("/tmp/supportbundle.zip", instance.BundlePath)
+	assert.Equal(30000, instance.Port, "expected published port to be preferred")
+	assert.Equal(int64(1500000000), instance.NanoCPUs)
+	assert.Equal(int64(4294967296), instance.Memory)
+	assert.Equal("v0.1.0", instance.Metadata.Version)
+
+	// stopped containers have no published ports
+	c.Ports = nil
+	c.State = "exited"
+	assert.Equal(30001, NewInstance(c).Port)
+
+	delete(c.Labels, HostPortLabel)
+	c.Labels[CPUsLabel] = "invalid"
+	instance = NewInstance(c)
+	assert.Equal(0, instance.Port)
+	assert.Equal(int64(0), instance.NanoCPUs)
+}
+
+func Test_ContainerState(t *testing.T) {
+	published := []types.Port{{IP: "127.0.0.1", PrivatePort: APIServerPort, PublicPort: 30000, Type: "tcp"}}
+	tests := []struct {
+		name     string
+		state    string
+		ports    []types.Port
+		expected State
+	}{
+		{name: "running with published port", state: "running", ports: published, expected: StateRunning},
+		{name: "running without published port", state: "running", expected: StateMissingPort},
+		{name: "running with other published port", state: "running", ports: []types.Port{{PrivatePort: 8080, PublicPort: 30000}}, expected: StateMissingPort},
+		{name: "created", state: "created", expected: StateCreated},
+		{name: "restarting", state: "restarting", expected: StateRestarting},
+		{name: "exited", state: "exited", expected: StateExited},
+		{name: "dead", state: "dead", expected: StateDead},
+		{name: "unknown", state: "hibernating", expected: StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, ContainerState(types.Container{State: tt.state, Ports: tt.ports}))
+		})
+	}
+}
+
+func Test_PublishedPort(t *testing.T) {
+	assert := require.New(t)
+	_, ok := PublishedPort(nil)
+	assert.False(ok)
+
+	_, ok = PublishedPort([]types.Port{{PrivatePort: APIServerPort, Type: "tcp"}})
+	assert.False(ok, "expected exposed but unpublished port to be ignored")
+
+	mapping, ok := PublishedPort([]types.Port{
+		{IP: "::", PrivatePort: APIServerPort, PublicPort: 30000, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: APIServerPort, PublicPort: 30000, Type: "tcp"},
+	})
+	assert.True(ok)
+	assert.Equal("0.0.0.0", mapping.IP, "expected ipv4 mapping to be preferred")
+
+	mapping, ok = PublishedPort([]types.Port{{IP: "::1", PrivatePort: APIServerPort, PublicPort: 30001, Type: "tcp"}})
+	assert.True(ok)
+	assert.Equal(uint16(30001), mapping.PublicPort)
+}
@@ -0,0 +1,99 @@
+package runtime
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// MetadataSchemaVersion is the version of the metadata labels set on instances created by this version of sim-cli.
+	// Instances created before metadata labels were introduced have schema version 0
+	MetadataSchemaVersion = 1
+
+	// BundleNameLabel records the path of the support bundle loaded by the instance
+	BundleNameLabel = "harvesterhci.io/bundle-name"
+	// SchemaVersionLabel records the version of the metadata label schema
+	SchemaVersionLabel = "sim-cli-managed/schema-version"
+	// BundleSHA256Label records the hex encoded sha256 digest of the support bundle
+	BundleSHA256Label = "sim-cli-managed/bundle-sha256"
+	// BundleSizeLabel records the size of the support bundle in bytes
+	BundleSizeLabel = "sim-cli-managed/bundle-size"
+	// CreatedLabel records when the instance was created in RFC3339 format
+	CreatedLabel = "sim-cli-managed/created"
+	// BaseImageLabel records the support-bundle-kit image the instance was created from
+	BaseImageLabel = "sim-cli-managed/base-image"
+	// BaseImageDigestLabel records the digest of the support-bundle-kit image the instance was created from
+	BaseImageDigestLabel = "sim-cli-managed/base-image-digest"
+	// VersionLabel records the version of sim-cli which created the instance
+	VersionLabel = "sim-cli-managed/version"
+	// CreatedByLabel records the user who created the instance
+	CreatedByLabel = "sim-cli-managed/created-by"
+)
+
+// Metadata describes how an instance was created, and is recorded in labels on the instance container and image
+type Metadata struct {
+	SchemaVersion   int       `json:"schemaVersion"`
+	BundlePath      string    `json:"bundlePath,omitempty"`
+	BundleSHA256    string    `json:"bundleSHA256,omitempty"`
+	BundleSize      int64     `json:"bundleSize,omitempty"`
+	Created         time.Time `json:"created,omitempty"`
+	BaseImage       string    `json:"baseImage,omitempty"`
+	BaseImageDigest string    `json:"baseImageDigest,omitempty"`
+	Version         string    `json:"version,omitempty"`
+	CreatedBy       string    `json:"createdBy,omitempty"`
+}
+
+// Labels returns the labels recording the metadata. Empty fields are omitted
+func (m Metadata) Labels() map[string]string {
+	labels := map[string]string{
+		SchemaVersionLabel: strconv.Itoa(MetadataSchemaVersion),
+	}
+
+	for k, v := range map[string]string{
+		BundleNameLabel:      m.BundlePath,
+		BundleSHA256Label:    m.BundleSHA256,
+		BaseImageLabel:       m.BaseImage,
+		BaseImageDigestLabel: m.BaseImageDigest,
+		VersionLabel:         m.Version,
+		CreatedByLabel:       m.CreatedBy,
+	} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+
+	if m.BundleSize != 0 {
+		labels[BundleSizeLabel] = strconv.FormatInt(m.BundleSize, 10)
+	}
+
+	if !m.Created.IsZero() {
+		labels[CreatedLabel] = m.Created.UTC().Format(time.RFC3339)
+	}
+	return labels
+}
+
+// ParseMetadata reads metadata from labels. Missing or invalid labels, for example on instances created by older
+// versions of sim-cli, are left empty
+func ParseMetadata(labels map[string]string) Metadata {
+	m := Metadata{
+		BundlePath:      labels[BundleNameLabel],
+		BundleSHA256:    labels[BundleSHA256Label],
+		BaseImage:       labels[BaseImageLabel],
+		BaseImageDigest: labels[BaseImageDigestLabel],
+		Version:         labels[VersionLabel],
+		CreatedBy:       labels[CreatedByLabel],
+	}
+
+	if v, err := strconv.Atoi(labels[SchemaVersionLabel]); err == nil {
+		m.SchemaVersion = v
+	}
+
+	if v, err := strconv.ParseInt(labels[BundleSizeLabel], 10, 64); err == nil {
+		m.BundleSize = v
+	}
+
+	if v, err := time.Parse(time.RFC3339, labels[CreatedLabel]); err == nil {
+		m.Created = v
+	}
+	return m
+}
@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Metadata(t *testing.T) {
+	assert := require.New(t)
+	created := time.Date(2024, 11, 18, 4, 34, 27, 0, time.UTC)
+	m := Metadata{
+		SchemaVersion:   MetadataSchemaVersion,
+		BundlePath:      "/tmp/supportbundle.zip",
+		BundleSHA256:    "7d0deb1cf3",
+		BundleSize:      1024,
+		Created:         created,
+		BaseImage:       "rancher/support-bundle-kit:dev",
+		BaseImageDigest: "rancher/support-bundle-kit@sha256:abcd",
+		Version:         "v0.1.0",
+		CreatedBy:       "support",
+	}
+
+	labels := m.Labels()
+	assert.Equal("1", labels[SchemaVersionLabel])
+	assert.Equal("1024", labels[BundleSizeLabel])
+	assert.Equal("2024-11-18T04:34:27Z", labels[CreatedLabel])
+	assert.Equal(m, ParseMetadata(labels))
+}
+
+func Test_ParseMetadataLegacyLabels(t *testing.T) {
+	assert := require.New(t)
+	m := ParseMetadata(map[string]string{
+		InstanceLabel:   "issue-7007",
+		BundleNameLabel: "/tmp/supportbundle.zip",
+	})
+	assert.Equal(Metadata{BundlePath: "/tmp/supportbundle.zip"}, m, "expected instances without metadata labels to have schema version 0")
+
+	m = ParseMetadata(map[string]string{
+		SchemaVersionLabel: "one",
+		BundleSizeLabel:    "large",
+		CreatedLabel:       "yesterday",
+		VersionLabel:       "v0.1.0",
+	})
+	assert.Equal(Metadata{Version: "v0.1.0"}, m, "expected invalid labels to be ignored")
+
+	assert.Equal(map[string]string{SchemaVersionLabel: "1"}, Metadata{}.Labels(), "expected empty fields to be omitted")
+}
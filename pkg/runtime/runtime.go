@@ -0,0 +1,170 @@
+package runtime
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	Docker = "docker"
+	Podman = "podman"
+
+	// InstanceLabel is set on all sim-cli managed containers, and contains the instance name
+	InstanceLabel = "sim-cli-managed"
+	// BundleVolumeLabel is set on containers which mount the bundle from a volume, and contains the volume name
+	BundleVolumeLabel = "sim-cli-managed/bundle-volume"
+	// BindAddressLabel records the host address the simulator api server is published on
+	BindAddressLabel = "sim-cli-managed/bind-address"
+	// HostPortLabel records the host port requested for the simulator api server
+	HostPortLabel = "sim-cli-managed/host-port"
+	// CPUsLabel records the cpu limit of the simulator container in nano cpus
+	CPUsLabel = "sim-cli-managed/nano-cpus"
+	// MemoryLabel records the memory limit of the simulator container in bytes
+	MemoryLabel = "sim-cli-managed/memory"
+)
+
+// RunOptions configure the simulator container for an instance
+type RunOptions struct {
+	InstanceName string
+	BundlePath   string
+	// Metadata is recorded in labels on the container
+	Metadata Metadata
+	// Image to run, defaults to the image built for the instance when empty
+	Image string
+	// Volume containing the extracted bundle, which is mounted read-only at /bundle when set
+	Volume string
+	// BindAddress is the host address the simulator api server is published on
+	BindAddress string
+	// HostPort is the host port the simulator api server is published on, a random port is used when 0
+	HostPort int
+	// NanoCPUs limits the cpu available to the simulator container in units of 1e-9 cpus, unlimited when 0
+	NanoCPUs int64
+	// Memory limits the memory available to the simulator container in bytes, unlimited when 0
+	Memory int64
+}
+
+// Instance is a sim-cli managed instance, as reported by the container runtime
+type Instance struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+	BundlePath  string `json:"bundlePath"`
+	Image       string `json:"image"`
+	State       State  `json:"state"`
+	Status      string `json:"status"`
+	BindAddress string `json:"bindAddress"`
+	// Port is the host port the simulator api server is published on, or 0 if it is not known
+	Port int `json:"port"`
+	// NanoCPUs is the cpu limit of the simulator container in units of 1e-9 cpus, unlimited when 0
+	NanoCPUs int64 `json:"nanoCPUs"`
+	// Memory is the memory limit of the simulator container in bytes, unlimited when 0
+	Memory   int64    `json:"memory"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Image is a sim-cli managed image built for one or more instances
+type Image struct {
+	ID string
+	// Instances are the names of the instances the image is tagged for
+	Instances []string
+	// Size is the disk space used by the image in bytes
+	Size int64
+}
+
+// Volume is a bundle volume created by sim-cli
+type Volume struct {
+	Name       string
+	BundlePath string
+	// Size is the disk space used by the volume in bytes, or -1 if it is not known
+	Size int64
+}
+
+// LogOptions control which container logs are returned
+type LogOptions struct {
+	// Follow streams new logs until the container stops
+	Follow bool
+	// Since only returns logs since a timestamp, or relative duration like 10m
+	Since string
+	// Tail is the number of lines to return from the end of the logs, or all
+	Tail string
+	// Timestamps prefixes each line with its timestamp
+	Timestamps bool
+}
+
+// ExecOptions configure a command run in a simulator container
+type ExecOptions struct {
+	Cmd []string
+	// TTY allocates a pseudo terminal for the command
+	TTY bool
+	// Height and Width are the initial size of the terminal when TTY is set
+	Height uint
+	Width  uint
+	// Stdin is attached to the command when set
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runtime defines the operations needed by sim-cli to manage the lifecycle of simulator instances
+// in a container runtime
+type Runtime interface {
+	// CreateImage builds an image for instanceName layering the bundle on top of baseImage, with additional labels.
+	// bundleDigest is the sha256 digest of the bundle, which identifies images that can be reused for the bundle
+	CreateImage(instanceName string, bundlePath string, bundleDigest string, baseImage string, labels map[string]string) error
+	// PullImage pulls ref from its registry
+	PullImage(ref string) error
+	// ImageDigest returns the repository digest of ref, or its ID if the image was not pulled from a registry
+	ImageDigest(ref string) (string, error)
+	// InspectImage returns the details of ref
+	InspectImage(ref string) (types.ImageInspect, error)
+	// ImageExists checks if ref is present in the local image store
+	ImageExists(ref string) (bool, error)
+	// SaveImage writes ref and its layers as a tar archive to w
+	SaveImage(ref string, w io.Writer) error
+	// LoadImage imports images from a tar archive generated by SaveImage
+	LoadImage(r io.Reader) error
+	// FindAllSimManagedImages returns all images built for sim-cli managed instances
+	FindAllSimManagedImages() ([]Image, error)
+	// RemoveImages removes images associated with instanceName
+	RemoveImages(instanceName string) error
+	// CreateBundleVolume extracts the bundle into a volume, reusing an existing volume for the bundle identified
+	// by bundleDigest, and returns the name of the volume
+	CreateBundleVolume(bundlePath string, bundleDigest string, baseImage string) (string, error)
+	// FindAllBundleVolumes returns all bundle volumes created by sim-cli
+	FindAllBundleVolumes() ([]Volume, error)
+	// RemoveVolume removes the volume unless it is still used by other containers
+	RemoveVolume(name string) error
+	// RunContainer runs the simulator for an instance
+	RunContainer(opts RunOptions) error
+	// FindRunningContainer returns running containers associated with instanceName
+	FindRunningContainer(instanceName string) ([]types.Container, error)
+	// FindContainer returns containers associated with instanceName, including stopped containers
+	FindContainer(instanceName string) ([]types.Container, error)
+	// InspectContainer returns details of the container associated with instanceName, including stopped containers
+	InspectContainer(instanceName string) (types.ContainerJSON, error)
+	// StopContainer stops containers associated with instanceName, retaining the containers
+	StopContainer(instanceName string) error
+	// StartContainer starts stopped containers associated with instanceName
+	StartContainer(instanceName string) error
+	// RemoveContainer stops and removes containers associated with instanceName
+	RemoveContainer(instanceName string) error
+	// QueryExposedMapping returns the host and port the simulator api server is reachable on
+	QueryExposedMapping(instanceName string) (string, string, error)
+	// ReadFile reads the file at path from the container associated with instanceName
+	ReadFile(instanceName string, path string) ([]byte, error)
+	// TailLogs returns the last lines of logs from the container associated with instanceName
+	TailLogs(instanceName string, lines int) (string, error)
+	// StreamLogs writes logs from the container associated with instanceName to stdout and stderr
+	StreamLogs(instanceName string, opts LogOptions, stdout, stderr io.Writer) error
+	// DaemonHost returns the address of the daemon the runtime is connected to, like unix:///var/run/docker.sock
+	DaemonHost() string
+	// Exec runs a command in the running container associated with instanceName and returns its exit code
+	Exec(instanceName string, opts ExecOptions) (int, error)
+	// FindAllSimManagedContainers returns all sim-cli managed containers, including stopped containers
+	FindAllSimManagedContainers() ([]types.Container, error)
+	// FindAllSimManagedInstances returns all sim-cli managed instances, including stopped instances
+	FindAllSimManagedInstances() ([]Instance, error)
+	// WithContext returns a copy of the runtime which uses ctx for all operations
+	WithContext(ctx context.Context) Runtime
+}
@@ -0,0 +1,52 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultSettingsPath = ".sim/config.yaml"
+)
+
+// Settings are user defaults for sim-cli loaded from $HOME/.sim/config.yaml. Values passed as
+// command flags take precedence over settings
+type Settings struct {
+	// Runtime is the container runtime used to manage simulator instances, either docker or podman
+	Runtime string `json:"runtime,omitempty"`
+	// CPUs is the default cpu limit for new simulator instances, like 1.5
+	CPUs json.Number `json:"cpus,omitempty"`
+	// Memory is the default memory limit for new simulator instances, like 4g
+	Memory string `json:"memory,omitempty"`
+}
+
+// Load reads settings from the default location in the users home directory. A missing
+// settings file is not an error and results in empty settings
+func Load() (*Settings, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching home directory: %w", err)
+	}
+	return LoadFile(filepath.Join(home, defaultSettingsPath))
+}
+
+// LoadFile reads settings from fileName
+func LoadFile(fileName string) (*Settings, error) {
+	s := &Settings{}
+	contents, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("error reading settings file %s: %w", fileName, err)
+	}
+
+	if err := yaml.UnmarshalStrict(contents, s); err != nil {
+		return nil, fmt.Errorf("error parsing settings file %s: %w", fileName, err)
+	}
+	return s, nil
+}
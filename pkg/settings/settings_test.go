@@ -0,0 +1,32 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadFile(t *testing.T) {
+	assert := require.New(t)
+	s, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NoError(err, "expected no error for missing settings file")
+	assert.Empty(s.Runtime)
+
+	fileName := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(os.WriteFile(fileName, []byte("runtime: podman\n"), 0600))
+	s, err = LoadFile(fileName)
+	assert.NoError(err)
+	assert.Equal("podman", s.Runtime)
+
+	assert.NoError(os.WriteFile(fileName, []byte("cpus: 1.5\nmemory: 4g\n"), 0600))
+	s, err = LoadFile(fileName)
+	assert.NoError(err)
+	assert.Equal("1.5", s.CPUs.String())
+	assert.Equal("4g", s.Memory)
+
+	assert.NoError(os.WriteFile(fileName, []byte("unknown: value\n"), 0600))
+	_, err = LoadFile(fileName)
+	assert.Error(err, "expected error for unknown settings")
+}